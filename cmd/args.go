@@ -3,7 +3,10 @@ package labee
 import (
 	"bufio"
 	"errors"
+	"fmt"
+	"io"
 	"os"
+	"strings"
 )
 
 var (
@@ -15,6 +18,11 @@ func pipeArgsAvailable() bool {
 	return (stat.Mode() & os.ModeCharDevice) == 0
 }
 
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && (stat.Mode()&os.ModeCharDevice) != 0
+}
+
 func readPipeArgs() []string {
 	scanner := bufio.NewScanner(os.Stdin)
 
@@ -25,3 +33,30 @@ func readPipeArgs() []string {
 
 	return pipeArgs
 }
+
+// Shared by every prompt, so that buffered input isn't lost between them
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// prompt asks the question on stderr and reads a line of the answer from stdin
+func prompt(question string) (string, error) {
+	fmt.Fprint(os.Stderr, question)
+
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// confirm asks a yes or no question, defaulting to no
+func confirm(question string) (bool, error) {
+	answer, err := prompt(question + " [y/N] ")
+	if err != nil {
+		return false, err
+	}
+
+	answer = strings.ToLower(answer)
+
+	return answer == "y" || answer == "yes", nil
+}
@@ -1,12 +1,14 @@
 package labee
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
 	"github.com/LeBulldoge/labee/internal/database"
+	ios "github.com/LeBulldoge/labee/internal/os"
 	"github.com/gookit/color"
 	"github.com/urfave/cli/v2"
 )
@@ -44,60 +46,46 @@ func Run() {
 				Usage:     "Query the storage for files. Filter by labels, filename or location.",
 				ArgsUsage: "[PATH]",
 				Aliases:   []string{"f"},
-				Flags: []cli.Flag{
-					flagInteractive,
-					&cli.StringSliceFlag{
-						Name:    "labels",
-						Aliases: []string{"l"},
-						Usage:   "List of comma separated labels",
-					},
-					&cli.StringFlag{
-						Name:    "name",
-						Aliases: []string{"n"},
-						Usage:   "Glob pattern to filter the filenames with",
-					},
-				},
+				Flags:     append(append([]cli.Flag{flagInteractive}, fileFilterFlags...), formatFlags...),
 				Action: func(ctx *cli.Context) error {
 					db, err := database.FromContext(ctx.Context)
 					if err != nil {
 						return err
 					}
 
-					labels := ctx.StringSlice("labels")
-					if err := doLabelsExist(db, labels); err != nil {
+					format, err := outputFormatFromContext(ctx)
+					if err != nil {
 						return err
 					}
 
-					pattern := ctx.String("name")
+					filter, err := fileFilterFromContext(ctx, db, ctx.Args().First())
+					if err != nil {
+						return err
+					}
 
-					var pathPrefix string
-					if ctx.Args().Present() {
-						pathPrefix, err = filepath.Abs(ctx.Args().First())
-						if err != nil {
-							return err
-						}
+					files, err := db.GetFilesWithFilter(filter)
+					if err != nil {
+						return err
 					}
 
-					var files []database.File
-					if len(labels) > 0 {
-						files, err = db.GetFilesFilteredWithLabels(labels, pattern, pathPrefix)
-						if err != nil {
-							return err
-						}
-					} else {
-						files, err = db.GetFilesFiltered(pattern, pathPrefix)
+					if interactive {
+						return openInteractiveFileMode(files)
+					}
+
+					if !format.isText() {
+						records, err := fileRecords(db, files)
 						if err != nil {
 							return err
 						}
-					}
 
-					if interactive {
-						return openInteractiveFileMode(files)
+						return format.write(os.Stdout, records)
 					}
 
 					// Just print out the file paths
 					for _, f := range files {
-						if f.Deleted {
+						if format.end != "\n" {
+							fmt.Print(f.Path + format.end)
+						} else if f.Deleted {
 							color.Yellowln(f.Path)
 						} else {
 							fmt.Println(f.Path)
@@ -112,6 +100,7 @@ func Run() {
 				Usage:     "Print out information about the specified files",
 				ArgsUsage: "[PATH]",
 				Aliases:   []string{"i"},
+				Flags:     formatFlags,
 				Action: func(ctx *cli.Context) error {
 					db, err := database.FromContext(ctx.Context)
 					if err != nil {
@@ -122,17 +111,67 @@ func Run() {
 						return ErrNoArgs
 					}
 
+					format, err := outputFormatFromContext(ctx)
+					if err != nil {
+						return err
+					}
+					if format.isText() && format.end != "\n" {
+						return fmt.Errorf("%w: --null can't be used with the text format of info", ErrInvalidFormat)
+					}
+
+					records := []fileRecord{}
 					filenames := ctx.Args().Slice()
 					for _, filename := range filenames {
-						labels, err := db.GetFileLabels(filename)
+						path, err := filepath.Abs(filename)
+						if err != nil {
+							return err
+						}
+
+						// Files beneath labeled directories inherit their labels, even when untracked
+						inherited, err := db.GetInheritedLabels(path)
+						if err != nil {
+							return err
+						}
+
+						file, err := db.GetFile(path)
+						if errors.Is(err, database.ErrFilesNotFound) {
+							// Mistyped paths get suggestions rather than the labels of their directory
+							if len(inherited) == 0 || !ios.FileExists(path) {
+								if format.isText() {
+									printUnknownFile(db, path)
+								} else {
+									log.Printf("%s is not in the storage, skipped", path)
+								}
+								continue
+							}
+							file = &database.File{Path: path}
+						} else if err != nil {
+							return err
+						}
+
+						labels, err := db.GetFileLabels(path)
+						if err != nil {
+							return err
+						}
+
+						note, err := db.GetNote(path)
 						if err != nil {
 							return err
 						}
 
-						printFileInfo(filename, labels)
+						if format.isText() {
+							printFileInfo(*file, labels, inherited, note)
+							continue
+						}
+
+						records = append(records, newFileRecord(*file, labels, inherited, note))
 					}
 
-					return nil
+					if format.isText() {
+						return nil
+					}
+
+					return format.write(os.Stdout, records)
 				},
 			},
 			{
@@ -145,7 +184,11 @@ func Run() {
 					&cli.StringSliceFlag{
 						Name:    "labels",
 						Aliases: []string{"l"},
-						Usage:   "Add comma separated labels to the file [-l \"labelA, labelB\"]. Creates labels if they don't exist",
+						Usage:   "Add comma separated labels to the file [-l \"labelA, labelB, priority=2\"]. Creates labels if they don't exist",
+					},
+					&cli.BoolFlag{
+						Name:  "hash",
+						Usage: "Hash the contents of the files, for 'labee dupes'",
 					},
 				},
 				Action: addLink,
@@ -161,6 +204,22 @@ func Run() {
 				},
 			},
 			editLabel,
+			manageLabels,
+			editNote,
+			listLabels,
+			search,
+			indexFiles,
+			refreshFiles,
+			hashStoredFiles,
+			listDuplicates,
+			relinkFiles,
+			moveFile,
+			pruneFiles,
+			showLog,
+			undoChanges,
+			showStats,
+			relatedLabels,
+			exportGraph,
 		},
 	}
 
@@ -208,17 +267,50 @@ func addLink(ctx *cli.Context) error {
 		return err
 	}
 
+	if ctx.Bool("hash") {
+		ids := []int64{}
+		for _, path := range absPaths {
+			file, err := db.GetFile(path)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, file.Id)
+		}
+
+		files, err := db.GetHashedFiles(ids...)
+		if err != nil {
+			return err
+		}
+
+		err = hashFiles(ctx.Context, db, files, false)
+		if err != nil {
+			return err
+		}
+	}
+
 	if quiet {
 		return nil
 	}
 
 	for _, path := range absPaths {
+		file, err := db.GetFile(path)
+		if err != nil {
+			return err
+		}
 		labels, err := db.GetFileLabels(path)
 		if err != nil {
 			return err
 		}
+		inherited, err := db.GetInheritedLabels(path)
+		if err != nil {
+			return err
+		}
+		note, err := db.GetNote(path)
+		if err != nil {
+			return err
+		}
 		fmt.Println("New file added:")
-		printFileInfo(path, labels)
+		printFileInfo(*file, labels, inherited, note)
 	}
 
 	return nil
@@ -2,6 +2,7 @@ package labee
 
 import (
 	"fmt"
+	"io/fs"
 	"log"
 	"path/filepath"
 	"strings"
@@ -11,22 +12,92 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-func printFileInfo(file string, labels []database.Label) {
-	color.Tag("us").Println(file)
+// Layout of the times shown to the user
+const timeLayout = "2006-01-02 15:04"
+
+func printFileInfo(file database.File, labels []database.Label, inherited []database.InheritedLabel, note string) {
+	color.Tag("us").Println(file.Path)
+
+	if file.AddedAt != 0 {
+		fmt.Println("Added: " + formatTime(file.AddedAt, timeLayout))
+	}
+
+	if file.ModifiedAt != 0 {
+		fmt.Printf("%s, %s, modified %s, %s\n",
+			file.Mime, formatSize(file.Size), formatTime(file.ModifiedAt, timeLayout), fs.FileMode(file.Mode))
+	}
 
 	if len(labels) == 0 {
 		fmt.Println("No labels have been assigned")
-		return
+	} else {
+		fmt.Print("Labels: ")
+
+		color.Println(colorLabels(labels))
+
+		var linkedAt int64
+		for _, l := range labels {
+			if l.LinkedAt > linkedAt {
+				linkedAt = l.LinkedAt
+			}
+		}
+		// Labels attached along with the file aren't worth repeating
+		if linkedAt > file.AddedAt {
+			fmt.Println("Last labeled: " + formatTime(linkedAt, timeLayout))
+		}
 	}
 
-	fmt.Print("Labels: ")
+	// Grouped by the directory they come from, closest first
+	for i := 0; i < len(inherited); {
+		from := inherited[i].From
+
+		group := []database.Label{}
+		for ; i < len(inherited) && inherited[i].From == from; i++ {
+			group = append(group, inherited[i].Label)
+		}
+
+		fmt.Printf("Inherited from %s: ", from)
+		color.Println(colorLabels(group))
+	}
 
+	if len(note) > 0 {
+		fmt.Println("Note:")
+		for _, line := range strings.Split(strings.TrimRight(note, "\n"), "\n") {
+			fmt.Println("  " + line)
+		}
+	}
+
+	fmt.Println()
+}
+
+func colorLabels(labels []database.Label) string {
 	cLabels := []string{}
 	for _, t := range labels {
-		cLabels = append(cLabels, color.HEX(t.Color).Sprint(t.Name))
+		name := t.Name
+		if len(t.Value) > 0 {
+			name += "=" + t.Value
+		}
+		cLabels = append(cLabels, color.HEX(t.Color).Sprint(name))
+	}
+
+	return strings.Join(cLabels, ", ")
+}
+
+// printUnknownFile reports a file missing from the storage, along with similarly named stored files
+func printUnknownFile(db *database.DB, file string) {
+	color.Tag("us").Println(file)
+	fmt.Print("File is not in the storage")
+
+	similar := db.GetSimilarFiles(file, suggestionCount)
+	if len(similar) == 0 {
+		fmt.Print("\n\n")
+		return
 	}
 
-	color.Println(strings.Join(cLabels, ", ") + "\n")
+	paths := []string{}
+	for _, f := range similar {
+		paths = append(paths, "'"+f.Path+"'")
+	}
+	fmt.Printf(". Did you mean %s?\n\n", joinSuggestions(paths))
 }
 
 var removeFile = &cli.Command{
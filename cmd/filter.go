@@ -0,0 +1,169 @@
+package labee
+
+import (
+	"path/filepath"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/urfave/cli/v2"
+)
+
+// Flags shared by every command which filters the stored files
+var fileFilterFlags = []cli.Flag{
+	&cli.StringSliceFlag{
+		Name:    "labels",
+		Aliases: []string{"l"},
+		Usage:   "List of comma separated labels. Saved searches can be used as labels [-l @name]",
+	},
+	&cli.StringFlag{
+		Name:    "query",
+		Aliases: []string{"q"},
+		Usage:   "Label expression [-q \"work and (urgent or review) and not archived\"]",
+	},
+	&cli.BoolFlag{
+		Name:  "exact",
+		Usage: "Don't match labels nested under the requested ones, e.g. 'project/docs' for 'project'",
+	},
+	&cli.StringFlag{
+		Name:    "name",
+		Aliases: []string{"n"},
+		Usage:   "Glob pattern to filter the filenames with",
+	},
+	&cli.BoolFlag{
+		Name:    "regex",
+		Aliases: []string{"E"},
+		Usage:   "Treat the --name pattern as a regular expression",
+	},
+	&cli.BoolFlag{
+		Name:    "ignore-case",
+		Aliases: []string{"I"},
+		Usage:   "Match the --name pattern case-insensitively",
+	},
+	&cli.BoolFlag{
+		Name:    "basename",
+		Aliases: []string{"b"},
+		Usage:   "Match the --name pattern against the filename only, instead of the full path",
+	},
+	&cli.StringFlag{
+		Name:    "contains",
+		Aliases: []string{"c"},
+		Usage:   "Full-text query over the file contents, see 'labee index' [-c \"quarterly AND report\"]",
+	},
+	&cli.StringFlag{
+		Name:  "note",
+		Usage: "Text the notes of the files must contain, ignoring case",
+	},
+	&cli.BoolFlag{
+		Name:  "missing",
+		Usage: "Only files which no longer exist",
+	},
+	&cli.BoolFlag{
+		Name:  "existing",
+		Usage: "Only files which still exist",
+	},
+	&cli.StringFlag{
+		Name:  "added-since",
+		Usage: "Only files added since the time: a duration like 12h, 7d or 2w, a date like 2006-01-02, today or yesterday",
+	},
+	&cli.StringFlag{
+		Name:  "added-before",
+		Usage: "Only files added before the time, see --added-since",
+	},
+	&cli.StringFlag{
+		Name:  "size",
+		Usage: "Only files of this size, recorded by 'add' or 'refresh': +10M for larger, -10M for smaller [--size +512k]",
+	},
+	&cli.StringFlag{
+		Name:  "modified-since",
+		Usage: "Only files modified since the time, see --added-since",
+	},
+	&cli.StringFlag{
+		Name:  "type",
+		Usage: "Only files of this type: dir, file or symlink",
+	},
+	&cli.StringFlag{
+		Name:  "mime",
+		Usage: "Glob pattern the MIME type of the files must match [--mime 'image/*']",
+	},
+	&cli.BoolFlag{
+		Name:    "untagged",
+		Aliases: []string{"u"},
+		Usage:   "Only files without any labels, including the ones inherited from directories",
+	},
+	&cli.BoolFlag{
+		Name:    "walk",
+		Aliases: []string{"w"},
+		Usage:   "Also list untracked files on disk beneath the matching directories. Sorts by path or name only",
+	},
+	&cli.StringFlag{
+		Name:  "sort",
+		Usage: "Sort the files by: path, name, added or labels",
+		Value: database.SortPath,
+	},
+	&cli.BoolFlag{
+		Name:    "reverse",
+		Aliases: []string{"r"},
+		Usage:   "Reverse the sort order",
+	},
+	&cli.IntFlag{
+		Name:  "limit",
+		Usage: "Return at most this many files",
+	},
+	&cli.IntFlag{
+		Name:  "offset",
+		Usage: "Skip this many files before returning any",
+	},
+}
+
+func fileFilterFromContext(ctx *cli.Context, db *database.DB, path string) (database.FileFilter, error) {
+	filter := database.FileFilter{
+		Labels:        ctx.StringSlice("labels"),
+		Query:         ctx.String("query"),
+		Exact:         ctx.Bool("exact"),
+		Pattern:       ctx.String("name"),
+		Regex:         ctx.Bool("regex"),
+		IgnoreCase:    ctx.Bool("ignore-case"),
+		Basename:      ctx.Bool("basename"),
+		Contains:      ctx.String("contains"),
+		Note:          ctx.String("note"),
+		Missing:       ctx.Bool("missing"),
+		Existing:      ctx.Bool("existing"),
+		AddedSince:    ctx.String("added-since"),
+		AddedBefore:   ctx.String("added-before"),
+		Size:          ctx.String("size"),
+		ModifiedSince: ctx.String("modified-since"),
+		Type:          ctx.String("type"),
+		Mime:          ctx.String("mime"),
+		Untagged:      ctx.Bool("untagged"),
+		Walk:          ctx.Bool("walk"),
+		Sort:          ctx.String("sort"),
+		Reverse:       ctx.Bool("reverse"),
+		Limit:         ctx.Int("limit"),
+		Offset:        ctx.Int("offset"),
+	}
+
+	labels := []string{}
+	for _, l := range filter.Labels {
+		labels = append(labels, database.LabelName(l))
+	}
+	if len(filter.Query) > 0 {
+		q, err := database.ParseQuery(filter.Query)
+		if err != nil {
+			return filter, err
+		}
+		labels = append(labels, q.Labels()...)
+	}
+
+	if err := doLabelsExist(db, labels); err != nil {
+		return filter, err
+	}
+
+	if len(path) > 0 {
+		var err error
+		filter.PathPrefix, err = filepath.Abs(path)
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,284 @@
+package labee
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	formatText     = "text"
+	formatJSON     = "json"
+	formatNDJSON   = "ndjson"
+	formatCSV      = "csv"
+	formatTSV      = "tsv"
+	formatTemplate = "template"
+)
+
+var (
+	flagFormat = &cli.StringFlag{
+		Name:    "format",
+		Aliases: []string{"f"},
+		Usage:   "Output format: text, json, ndjson, csv, tsv or template",
+		Value:   formatText,
+	}
+
+	flagTemplate = &cli.StringFlag{
+		Name:    "template",
+		Aliases: []string{"t"},
+		Usage:   "Go text/template executed for every file, implies '--format template' [-t '{{.Id}} {{.Path}}']",
+	}
+
+	flagNull = &cli.BoolFlag{
+		Name:    "null",
+		Aliases: []string{"0"},
+		Usage:   "Separate output records with NUL instead of newline, to be used with 'xargs -0'",
+	}
+
+	formatFlags = []cli.Flag{flagFormat, flagTemplate, flagNull}
+)
+
+type labelRecord struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+	Value string `json:"value,omitempty"`
+	// Directory the label is inherited from
+	From     string `json:"from,omitempty"`
+	LinkedAt string `json:"linkedAt,omitempty"`
+}
+
+type fileRecord struct {
+	Id         int64         `json:"id"`
+	Path       string        `json:"path"`
+	AddedAt    string        `json:"addedAt,omitempty"`
+	Size       int64         `json:"size,omitempty"`
+	ModifiedAt string        `json:"modifiedAt,omitempty"`
+	Mode       string        `json:"mode,omitempty"`
+	Mime       string        `json:"mime,omitempty"`
+	Labels     []labelRecord `json:"labels"`
+	Inherited  []labelRecord `json:"inherited,omitempty"`
+	Note       string        `json:"note,omitempty"`
+	Deleted    bool          `json:"deleted"`
+}
+
+func newLabelRecord(l database.Label) labelRecord {
+	lr := labelRecord{Name: l.Name, Value: l.Value, LinkedAt: formatTime(l.LinkedAt, time.RFC3339)}
+	if l.Color != colorNone {
+		lr.Color = l.Color
+	}
+
+	return lr
+}
+
+// formatTime formats a unix time, leaving unknown times empty
+func formatTime(unix int64, layout string) string {
+	if unix == 0 {
+		return ""
+	}
+
+	return time.Unix(unix, 0).Format(layout)
+}
+
+func newFileRecord(file database.File, labels []database.Label, inherited []database.InheritedLabel, note string) fileRecord {
+	rec := fileRecord{
+		Id:         file.Id,
+		Path:       file.Path,
+		AddedAt:    formatTime(file.AddedAt, time.RFC3339),
+		Size:       file.Size,
+		ModifiedAt: formatTime(file.ModifiedAt, time.RFC3339),
+		Mime:       file.Mime,
+		Labels:     []labelRecord{},
+		Note:       note,
+		Deleted:    file.Deleted,
+	}
+
+	if file.ModifiedAt != 0 {
+		rec.Mode = fs.FileMode(file.Mode).String()
+	}
+
+	for _, l := range labels {
+		rec.Labels = append(rec.Labels, newLabelRecord(l))
+	}
+
+	for _, l := range inherited {
+		lr := newLabelRecord(l.Label)
+		lr.From = l.From
+		rec.Inherited = append(rec.Inherited, lr)
+	}
+
+	return rec
+}
+
+// LabelNames joins the label names and their values with commas, for use in templates
+func (r fileRecord) LabelNames() string {
+	names := []string{}
+	for _, l := range r.Labels {
+		if len(l.Value) > 0 {
+			names = append(names, l.Name+"="+l.Value)
+		} else {
+			names = append(names, l.Name)
+		}
+	}
+
+	return strings.Join(names, ",")
+}
+
+// Name returns the last element of the path, for use in templates
+func (r fileRecord) Name() string {
+	return filepath.Base(r.Path)
+}
+
+type outputFormat struct {
+	kind string
+	tmpl *template.Template
+	// Record terminator
+	end string
+}
+
+var ErrInvalidFormat = errors.New("invalid output format")
+
+func outputFormatFromContext(ctx *cli.Context) (*outputFormat, error) {
+	format := &outputFormat{kind: ctx.String("format"), end: "\n"}
+
+	if ctx.IsSet("template") {
+		if ctx.IsSet("format") && format.kind != formatTemplate {
+			return nil, fmt.Errorf("%w: --template can't be used with '--format %s'", ErrInvalidFormat, format.kind)
+		}
+		format.kind = formatTemplate
+	}
+
+	switch format.kind {
+	case formatText, formatJSON, formatNDJSON, formatCSV, formatTSV:
+	case formatTemplate:
+		if !ctx.IsSet("template") {
+			return nil, fmt.Errorf("%w: '--format template' requires --template", ErrInvalidFormat)
+		}
+
+		tmpl, err := template.New("format").Parse(ctx.String("template"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+		}
+		format.tmpl = tmpl
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFormat, format.kind)
+	}
+
+	if ctx.Bool("null") {
+		if format.kind != formatText && format.kind != formatTemplate {
+			return nil, fmt.Errorf("%w: --null can only be used with text or template formats", ErrInvalidFormat)
+		}
+		format.end = "\x00"
+	}
+
+	return format, nil
+}
+
+func (f *outputFormat) isText() bool {
+	return f.kind == formatText
+}
+
+func (f *outputFormat) write(w io.Writer, records []fileRecord) error {
+	switch f.kind {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case formatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, rec := range records {
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatCSV, formatTSV:
+		return writeDelimited(w, records, f.kind == formatTSV)
+	case formatTemplate:
+		for _, rec := range records {
+			if err := f.tmpl.Execute(w, rec); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, f.end); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidFormat, f.kind)
+}
+
+func writeDelimited(w io.Writer, records []fileRecord, tabs bool) error {
+	cw := csv.NewWriter(w)
+	if tabs {
+		cw.Comma = '\t'
+	}
+
+	err := cw.Write([]string{"id", "path", "labels", "colors", "deleted"})
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		// Colors line up with the labels, unless none of them has one
+		colors := []string{}
+		colored := false
+		for _, l := range rec.Labels {
+			colors = append(colors, l.Color)
+			colored = colored || len(l.Color) > 0
+		}
+		if !colored {
+			colors = nil
+		}
+
+		err := cw.Write([]string{
+			strconv.FormatInt(rec.Id, 10),
+			rec.Path,
+			rec.LabelNames(),
+			strings.Join(colors, ","),
+			strconv.FormatBool(rec.Deleted),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func fileRecords(db *database.DB, files []database.File) ([]fileRecord, error) {
+	records := []fileRecord{}
+	for _, f := range files {
+		labels, err := db.GetFileLabels(f.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		inherited, err := db.GetInheritedLabels(f.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		note, err := db.GetNote(f.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, newFileRecord(f, labels, inherited, note))
+	}
+
+	return records, nil
+}
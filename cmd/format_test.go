@@ -0,0 +1,28 @@
+package labee
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteDelimited(t *testing.T) {
+	records := []fileRecord{
+		{Id: 1, Path: "/a", Labels: []labelRecord{{Name: "work", Color: "#ff0000"}, {Name: "priority", Value: "2"}}},
+		{Id: 2, Path: "/b", Labels: []labelRecord{{Name: "draft"}, {Name: "todo"}}},
+		{Id: 3, Path: "/c", Labels: []labelRecord{}, Deleted: true},
+	}
+
+	var buf bytes.Buffer
+	if err := writeDelimited(&buf, records, false); err != nil {
+		t.Fatalf("failed writing csv: %v", err)
+	}
+
+	expected := `id,path,labels,colors,deleted
+1,/a,"work,priority=2","#ff0000,",false
+2,/b,"draft,todo",,false
+3,/c,,,true
+`
+	if buf.String() != expected {
+		t.Errorf("csv:\ngot\n%s\nexpected\n%s", buf.String(), expected)
+	}
+}
@@ -0,0 +1,183 @@
+package labee
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+)
+
+const formatDot = "dot"
+
+type relatedLabelRecord struct {
+	Name   string `json:"name"`
+	Color  string `json:"color,omitempty"`
+	Shared int    `json:"shared"`
+	// Share of the files of the queried label
+	Ratio float64 `json:"ratio"`
+}
+
+type graphRecord struct {
+	Nodes []labelCountRecord   `json:"nodes"`
+	Edges []database.LabelEdge `json:"edges"`
+}
+
+var relatedLabels = &cli.Command{
+	Name:      "related",
+	Usage:     "List labels most often attached to the same files as the label",
+	ArgsUsage: "[label]",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "Amount of labels to list, 0 for all",
+			Value: 10,
+		},
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   "Output format: text or json",
+			Value:   formatText,
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if !ctx.Args().Present() {
+			return ErrNoArgs
+		}
+		label := ctx.Args().First()
+
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		format := ctx.String("format")
+		if format != formatText && format != formatJSON {
+			return fmt.Errorf("%w: %s", ErrInvalidFormat, format)
+		}
+
+		if err := doLabelsExist(db, []string{label}); err != nil {
+			return err
+		}
+
+		related, files, err := db.GetRelatedLabels(label)
+		if err != nil {
+			return err
+		}
+
+		if limit := ctx.Int("limit"); limit > 0 && len(related) > limit {
+			related = related[:limit]
+		}
+
+		records := []relatedLabelRecord{}
+		for _, r := range related {
+			rec := relatedLabelRecord{Name: r.Name, Shared: r.Shared}
+			if r.Color != colorNone {
+				rec.Color = r.Color
+			}
+			if files > 0 {
+				rec.Ratio = float64(r.Shared) / float64(files)
+			}
+			records = append(records, rec)
+		}
+
+		if format == formatJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(records)
+		}
+
+		width := 0
+		for _, rec := range records {
+			if n := utf8.RuneCountInString(rec.Name); n > width {
+				width = n
+			}
+		}
+
+		for _, rec := range records {
+			pad := strings.Repeat(" ", width-utf8.RuneCountInString(rec.Name))
+			color.Printf("%s %s%s  %d of %s (%.0f%%)\n",
+				swatch(rec.Color), rec.Name, pad, rec.Shared, pluralize(files, "file"), rec.Ratio*100)
+		}
+
+		return nil
+	},
+}
+
+func writeDot(w io.Writer, graph graphRecord) error {
+	var sb strings.Builder
+
+	sb.WriteString("graph labels {\n")
+	for _, n := range graph.Nodes {
+		fmt.Fprintf(&sb, "  %s [label=%s", strconv.Quote(n.Name), strconv.Quote(fmt.Sprintf("%s (%d)", n.Name, n.Files)))
+		if len(n.Color) > 0 {
+			fmt.Fprintf(&sb, ", color=%s", strconv.Quote(n.Color))
+		}
+		sb.WriteString("];\n")
+	}
+	for _, e := range graph.Edges {
+		fmt.Fprintf(&sb, "  %s -- %s [weight=%d, label=\"%d\"];\n", strconv.Quote(e.From), strconv.Quote(e.To), e.Weight, e.Weight)
+	}
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, sb.String())
+
+	return err
+}
+
+var exportGraph = &cli.Command{
+	Name:  "graph",
+	Usage: "Export the graph of labels attached to the same files",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   "Output format: dot or json",
+			Value:   formatDot,
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		format := ctx.String("format")
+		if format != formatDot && format != formatJSON {
+			return fmt.Errorf("%w: %s", ErrInvalidFormat, format)
+		}
+
+		usage, err := db.GetLabelUsage()
+		if err != nil {
+			return err
+		}
+
+		graph := graphRecord{Nodes: []labelCountRecord{}}
+		for _, u := range usage {
+			n := labelCountRecord{Name: u.Name, Files: u.Files}
+			if u.Color != colorNone {
+				n.Color = u.Color
+			}
+			graph.Nodes = append(graph.Nodes, n)
+		}
+
+		graph.Edges, err = db.GetLabelEdges()
+		if err != nil {
+			return err
+		}
+
+		if format == formatJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(graph)
+		}
+
+		return writeDot(os.Stdout, graph)
+	},
+}
@@ -0,0 +1,307 @@
+package labee
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	ios "github.com/LeBulldoge/labee/internal/os"
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+)
+
+// Amount of hashes stored per transaction
+const hashBatchSize = 100
+
+type hashResult struct {
+	entry database.HashEntry
+	path  string
+	err   error
+}
+
+// hashFiles hashes the contents of the files in parallel, skipping the ones which
+// haven't changed since they were last hashed unless forced. Progress goes to stderr.
+func hashFiles(ctx context.Context, db *database.DB, files []database.HashedFile, force bool) error {
+	var pending []database.HashedFile
+	skipped := 0
+	for _, f := range files {
+		stat, err := os.Stat(f.Path)
+		if err != nil || !stat.Mode().IsRegular() {
+			skipped++
+			continue
+		}
+
+		if stat.ModTime().UnixNano() == f.HashedMtime && len(f.Hash) > 0 && !force {
+			skipped++
+			continue
+		}
+
+		pending = append(pending, f)
+	}
+
+	progress := !quiet && isTerminal(os.Stderr)
+
+	jobs := make(chan database.HashedFile)
+	results := make(chan hashResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range jobs {
+				res := hashResult{path: f.Path, entry: database.HashEntry{FileId: f.Id}}
+
+				// The time is taken first, so that changes made while hashing cause a rehash
+				stat, err := os.Stat(f.Path)
+				if err == nil {
+					res.entry.Mtime = stat.ModTime().UnixNano()
+					res.entry.Hash, err = ios.Hash(f.Path)
+				}
+				res.err = err
+
+				results <- res
+			}
+		}()
+	}
+
+	go func() {
+		for _, f := range pending {
+			jobs <- f
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var (
+		entries []database.HashEntry
+		hashed  int
+		dberr   error
+	)
+	for res := range results {
+		if res.err != nil {
+			log.Printf("couldn't hash %s: %v", res.path, res.err)
+			skipped++
+		} else {
+			entries = append(entries, res.entry)
+			hashed++
+		}
+
+		if progress {
+			fmt.Fprintf(os.Stderr, "\rhashed %d of %d", hashed, len(pending))
+		}
+
+		// Results are drained even after a failure, to let the workers finish
+		if len(entries) >= hashBatchSize && dberr == nil {
+			dberr = db.UpdateHashes(ctx, entries)
+			entries = nil
+		}
+	}
+
+	if dberr != nil {
+		return dberr
+	}
+
+	if err := db.UpdateHashes(ctx, entries); err != nil {
+		return err
+	}
+
+	if progress && len(pending) > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	if !quiet {
+		log.Printf("%d hashed, %d skipped", hashed, skipped)
+	}
+
+	return nil
+}
+
+var hashStoredFiles = &cli.Command{
+	Name:      "hash",
+	Usage:     "Hash the contents of stored files, for 'labee dupes'. Only changed files are rehashed",
+	ArgsUsage: "[PATH...]",
+	Flags: []cli.Flag{
+		flagQuiet,
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "Rehash every file, even if it hasn't changed",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		stored, err := storedFilesUnder(db, ctx.Args().Slice())
+		if err != nil {
+			return err
+		}
+		if len(stored) == 0 {
+			return nil
+		}
+
+		ids := []int64{}
+		for _, f := range stored {
+			ids = append(ids, f.Id)
+		}
+
+		files, err := db.GetHashedFiles(ids...)
+		if err != nil {
+			return err
+		}
+
+		return hashFiles(ctx.Context, db, files, ctx.Bool("force"))
+	},
+}
+
+type duplicateRecord struct {
+	Hash   string        `json:"hash"`
+	Size   int64         `json:"size"`
+	Files  []fileRecord  `json:"files"`
+	Labels []labelRecord `json:"labels"`
+}
+
+var listDuplicates = &cli.Command{
+	Name:  "dupes",
+	Usage: "List stored files sharing the same contents, along with all of their labels. See 'labee hash'",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "merge",
+			Aliases: []string{"m"},
+			Usage:   "Ask which copy of every duplicate to attach all of the labels to. The other copies keep their labels",
+		},
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   "Output format: text or json",
+			Value:   formatText,
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		format := ctx.String("format")
+		if format != formatText && format != formatJSON {
+			return fmt.Errorf("%w: %s", ErrInvalidFormat, format)
+		}
+		if format == formatJSON && ctx.Bool("merge") {
+			return fmt.Errorf("%w: --merge can only be used with the text format", ErrInvalidFormat)
+		}
+
+		groups, err := db.GetDuplicateFiles()
+		if err != nil {
+			return err
+		}
+
+		records := []duplicateRecord{}
+		for _, group := range groups {
+			rec := duplicateRecord{Hash: group[0].Hash, Size: group[0].Size, Labels: []labelRecord{}}
+
+			seen := map[string]bool{}
+			for _, f := range group {
+				labels, err := db.GetFileLabels(f.Path)
+				if err != nil {
+					return err
+				}
+
+				for _, l := range labels {
+					if !seen[l.Name] {
+						seen[l.Name] = true
+						rec.Labels = append(rec.Labels, newLabelRecord(l))
+					}
+				}
+
+				rec.Files = append(rec.Files, newFileRecord(f.File, labels, nil, ""))
+			}
+
+			records = append(records, rec)
+		}
+
+		if format == formatJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(records)
+		}
+
+		if len(records) == 0 {
+			fmt.Println("No duplicates found")
+			return nil
+		}
+
+		for i, rec := range records {
+			color.Tag("us").Println(fmt.Sprintf("%d copies, %s", len(rec.Files), formatSize(rec.Size)))
+			for n, f := range rec.Files {
+				line := fmt.Sprintf("  %d. %s", n+1, f.Path)
+				if f.Deleted {
+					color.Yellowln(line)
+				} else {
+					fmt.Println(line)
+				}
+			}
+
+			if len(rec.Labels) > 0 {
+				names := []string{}
+				for _, l := range rec.Labels {
+					names = append(names, color.HEX(l.Color).Sprint(l.Name))
+				}
+				color.Println("Labels: " + strings.Join(names, ", "))
+			}
+
+			if ctx.Bool("merge") && len(rec.Labels) > 0 {
+				err := mergeDuplicate(ctx.Context, db, groups[i])
+				if err != nil {
+					return err
+				}
+			}
+
+			fmt.Println()
+		}
+
+		return nil
+	},
+}
+
+// mergeDuplicate asks which copy should get the labels of every copy and attaches them there
+func mergeDuplicate(ctx context.Context, db *database.DB, group []database.HashedFile) error {
+	answer, err := prompt(fmt.Sprintf("Attach all of the labels to which copy? [1-%d, empty to skip] ", len(group)))
+	if err != nil {
+		return err
+	}
+	if len(answer) == 0 {
+		return nil
+	}
+
+	n, err := strconv.Atoi(answer)
+	if err != nil || n < 1 || n > len(group) {
+		return fmt.Errorf("'%s' is not one of the copies", answer)
+	}
+
+	keep := group[n-1]
+	var copies []int64
+	for _, f := range group {
+		if f.Id != keep.Id {
+			copies = append(copies, f.Id)
+		}
+	}
+
+	err = db.MergeDuplicateLabels(ctx, keep.Id, copies)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("labels attached to %s", keep.Path)
+
+	return nil
+}
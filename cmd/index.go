@@ -0,0 +1,42 @@
+package labee
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/urfave/cli/v2"
+)
+
+var indexFiles = &cli.Command{
+	Name:  "index",
+	Usage: "Index the contents of stored files for 'find --contains'. Only changed files are reindexed",
+	Flags: []cli.Flag{
+		flagQuiet,
+		&cli.BoolFlag{
+			Name:  "force",
+			Usage: "Reindex every file, even if it hasn't changed",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		stats, err := db.IndexFiles(ctx.Context, ctx.Bool("force"))
+		if err != nil {
+			return err
+		}
+
+		for _, err := range stats.Errors {
+			log.Print(err)
+		}
+
+		if !quiet {
+			fmt.Printf("%d indexed, %d unchanged, %d skipped\n", stats.Indexed, stats.Unchanged, stats.Skipped)
+		}
+
+		return nil
+	},
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"strings"
 
 	"github.com/LeBulldoge/labee/internal/database"
 	"github.com/gookit/color"
@@ -13,8 +14,20 @@ import (
 
 const (
 	colorNone = "NONE"
+
+	// Amount of alternatives suggested for misspelled names
+	suggestionCount = 3
 )
 
+// joinSuggestions lists the suggestions as "a, b or c"
+func joinSuggestions(s []string) string {
+	if len(s) < 2 {
+		return strings.Join(s, "")
+	}
+
+	return strings.Join(s[:len(s)-1], ", ") + " or " + s[len(s)-1]
+}
+
 func isValidColor(hexColor string) (bool, error) {
 	return regexp.MatchString("^#[0-9A-F]{6}$", hexColor)
 }
@@ -39,16 +52,27 @@ func colorize(str string, hexColor string) (string, error) {
 func doLabelsExist(db *database.DB, labelNames []string) error {
 	var err error
 	for _, label := range labelNames {
-		if db.LabelExists(label) {
+		if database.IsSavedSearch(label) {
+			name := strings.TrimPrefix(label, database.SavedSearchPrefix)
+			if !db.SavedSearchExists(name) {
+				err = errors.Join(err, fmt.Errorf("saved search '%s' does not exist", label))
+			}
+			continue
+		}
+
+		// Parents match the labels nested under them, even if they don't exist themselves
+		if db.LabelExists(label) || db.LabelHasChildren(label) {
 			continue
 		}
 
 		e := fmt.Errorf("label '%s' does not exist", label)
-		if len(label) >= 3 {
-			if similar := db.GetSimilarLabel(label); similar != nil {
-				cl, _ := colorize(similar.Name, similar.Color)
-				e = fmt.Errorf("%w. did you mean '%s'?", e, cl)
+		if similar := db.GetSimilarLabels(label, suggestionCount); len(similar) > 0 {
+			names := []string{}
+			for _, l := range similar {
+				cl, _ := colorize(l.Name, l.Color)
+				names = append(names, "'"+cl+"'")
 			}
+			e = fmt.Errorf("%w. did you mean %s?", e, joinSuggestions(names))
 		}
 		err = errors.Join(err, e)
 	}
@@ -80,7 +104,7 @@ var (
 				}
 			}
 
-			fmt.Println("File removed from storage")
+			fmt.Printf("%s removed from storage\n", pluralize(len(args), "label"))
 
 			return nil
 		},
@@ -128,4 +152,180 @@ var (
 			return nil
 		},
 	}
+
+	aliasLabel = &cli.Command{
+		Name:      "alias",
+		Usage:     "Register alternative names for a label",
+		ArgsUsage: "[label] [alias...]",
+		Aliases:   []string{"a"},
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "merge",
+				Usage: "Merge existing labels with those names into the label, moving their links, aliases and rules",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.Args().Len() < 2 {
+				return errors.New("please provide a label and its aliases")
+			}
+			label := ctx.Args().First()
+			aliases := ctx.Args().Tail()
+
+			for _, name := range append([]string{label}, aliases...) {
+				if database.LabelName(name) != name || database.IsSavedSearch(name) {
+					return fmt.Errorf("'%s' is not a valid label name", name)
+				}
+			}
+
+			db, err := database.FromContext(ctx.Context)
+			if err != nil {
+				return err
+			}
+
+			merged, err := db.AddLabelAliases(ctx.Context, label, aliases, ctx.Bool("merge"))
+			if errors.Is(err, database.ErrAliasIsLabel) {
+				return fmt.Errorf("%w, pass --merge to merge it into '%s'", err, label)
+			} else if err != nil {
+				return err
+			}
+
+			for _, m := range merged {
+				log.Printf("label '%s' merged into '%s'", m, label)
+			}
+			log.Printf("aliases of '%s' added: %s", label, strings.Join(aliases, ", "))
+
+			return nil
+		},
+	}
+
+	unaliasLabel = &cli.Command{
+		Name:      "unalias",
+		Usage:     "Remove alternative names of labels",
+		ArgsUsage: "[alias...]",
+		Aliases:   []string{"u"},
+		Action: func(ctx *cli.Context) error {
+			if !ctx.Args().Present() {
+				return ErrNoArgs
+			}
+
+			db, err := database.FromContext(ctx.Context)
+			if err != nil {
+				return err
+			}
+
+			return db.DeleteLabelAliases(ctx.Context, ctx.Args().Slice())
+		},
+	}
+
+	listAliases = &cli.Command{
+		Name:    "aliases",
+		Usage:   "List the alternative names of labels",
+		Aliases: []string{"l"},
+		Action: func(ctx *cli.Context) error {
+			db, err := database.FromContext(ctx.Context)
+			if err != nil {
+				return err
+			}
+
+			aliases, err := db.GetLabelAliases()
+			if err != nil {
+				return err
+			}
+
+			for _, a := range aliases {
+				fmt.Printf("%s -> %s\n", a.Name, a.Label)
+			}
+
+			return nil
+		},
+	}
+
+	implyLabel = &cli.Command{
+		Name:      "imply",
+		Usage:     "Attach the implied labels whenever the label is attached, including the files it's already attached to",
+		ArgsUsage: "[label] [implied...]",
+		Aliases:   []string{"i"},
+		Action: func(ctx *cli.Context) error {
+			if ctx.Args().Len() < 2 {
+				return errors.New("please provide a label and the labels it implies")
+			}
+			label := ctx.Args().First()
+			implied := ctx.Args().Tail()
+
+			for _, name := range append([]string{label}, implied...) {
+				if database.LabelName(name) != name || database.IsSavedSearch(name) {
+					return fmt.Errorf("'%s' is not a valid label name", name)
+				}
+			}
+
+			db, err := database.FromContext(ctx.Context)
+			if err != nil {
+				return err
+			}
+
+			added, err := db.AddLabelImplications(ctx.Context, label, implied)
+			if err != nil {
+				return err
+			}
+
+			log.Printf("'%s' now implies %s, %s added", label, strings.Join(implied, ", "), pluralize(int(added), "link"))
+
+			return nil
+		},
+	}
+
+	unimplyLabel = &cli.Command{
+		Name:      "unimply",
+		Usage:     "Remove implication rules. Labels already attached by them are kept",
+		ArgsUsage: "[label] [implied...]",
+		Action: func(ctx *cli.Context) error {
+			if ctx.Args().Len() < 2 {
+				return errors.New("please provide a label and the labels it implies")
+			}
+
+			db, err := database.FromContext(ctx.Context)
+			if err != nil {
+				return err
+			}
+
+			return db.DeleteLabelImplications(ctx.Context, ctx.Args().First(), ctx.Args().Tail())
+		},
+	}
+
+	listRules = &cli.Command{
+		Name:    "rules",
+		Usage:   "List the implication rules",
+		Aliases: []string{"r"},
+		Action: func(ctx *cli.Context) error {
+			db, err := database.FromContext(ctx.Context)
+			if err != nil {
+				return err
+			}
+
+			rules, err := db.GetLabelImplications()
+			if err != nil {
+				return err
+			}
+
+			for _, r := range rules {
+				fmt.Printf("%s => %s\n", r.Label, r.Implied)
+			}
+
+			return nil
+		},
+	}
+
+	manageLabels = &cli.Command{
+		Name:      "label",
+		Usage:     "Manage labels",
+		ArgsUsage: "[subcommand]",
+		Subcommands: []*cli.Command{
+			aliasLabel,
+			unaliasLabel,
+			listAliases,
+			implyLabel,
+			unimplyLabel,
+			listRules,
+		},
+	}
 )
@@ -0,0 +1,281 @@
+package labee
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	labelSortName  = "name"
+	labelSortUsage = "usage"
+)
+
+type labelUsageRecord struct {
+	Name    string `json:"name"`
+	Color   string `json:"color,omitempty"`
+	Files   int    `json:"files"`
+	Missing int    `json:"missing"`
+	// Alternative names of the label
+	Aliases []string `json:"aliases,omitempty"`
+	// Set for saved searches, which act as labels
+	Search bool `json:"search,omitempty"`
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// swatch returns a colored square for the label, or a blank for labels without color
+func swatch(hexColor string) string {
+	s, err := colorize("■", hexColor)
+	if err != nil || s == "■" {
+		return " "
+	}
+
+	return s
+}
+
+func labelUsageRecords(db *database.DB, orphans bool) ([]labelUsageRecord, error) {
+	usage, err := db.GetLabelUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := db.GetLabelAliases()
+	if err != nil {
+		return nil, err
+	}
+
+	labelAliases := map[string][]string{}
+	for _, a := range aliases {
+		labelAliases[a.Label] = append(labelAliases[a.Label], a.Name)
+	}
+
+	records := []labelUsageRecord{}
+	for _, u := range usage {
+		if orphans && u.Files > 0 {
+			continue
+		}
+
+		rec := labelUsageRecord{Name: u.Name, Files: u.Files, Missing: u.Missing, Aliases: labelAliases[u.Name]}
+		if u.Color != colorNone {
+			rec.Color = u.Color
+		}
+		records = append(records, rec)
+	}
+
+	searches, err := db.GetAllSavedSearches()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range searches {
+		files, err := db.GetFilesWithFilter(s.Filter)
+		if err != nil {
+			// Keep listing a broken search, so it can be fixed or removed
+			log.Printf("saved search %s%s: %v", database.SavedSearchPrefix, s.Name, err)
+		}
+
+		if orphans && len(files) > 0 {
+			continue
+		}
+
+		rec := labelUsageRecord{Name: database.SavedSearchPrefix + s.Name, Files: len(files), Search: true}
+		for _, f := range files {
+			if f.Deleted {
+				rec.Missing++
+			}
+		}
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+// printLabelDetails finishes the line of a label with its missing files and aliases
+func printLabelDetails(rec labelUsageRecord) {
+	if rec.Missing > 0 {
+		fmt.Printf(" (%d missing)", rec.Missing)
+	}
+	if len(rec.Aliases) > 0 {
+		fmt.Printf(", aka %s", strings.Join(rec.Aliases, ", "))
+	}
+	fmt.Println()
+}
+
+type labelNode struct {
+	// Last element of the label name
+	name string
+	// Nil for parents which aren't labels themselves
+	record   *labelUsageRecord
+	children []*labelNode
+}
+
+// buildLabelTree nests the labels by their names, keeping the order of the records
+func buildLabelTree(records []labelUsageRecord) []*labelNode {
+	root := &labelNode{}
+	nodes := map[string]*labelNode{"": root}
+
+	for i := range records {
+		parent := root
+		path := ""
+		for _, part := range strings.Split(records[i].Name, database.LabelSeparator) {
+			if len(path) > 0 {
+				path += database.LabelSeparator
+			}
+			path += part
+
+			node, ok := nodes[path]
+			if !ok {
+				node = &labelNode{name: part}
+				nodes[path] = node
+				parent.children = append(parent.children, node)
+			}
+			parent = node
+		}
+
+		parent.record = &records[i]
+	}
+
+	return root.children
+}
+
+func printLabelTree(nodes []*labelNode, indent string, top bool) {
+	for i, node := range nodes {
+		last := i == len(nodes)-1
+
+		connector, childIndent := "", ""
+		if !top {
+			connector, childIndent = "├── ", "│   "
+			if last {
+				connector, childIndent = "└── ", "    "
+			}
+		}
+
+		if node.record == nil {
+			fmt.Printf("%s%s  %s\n", indent, connector, node.name)
+		} else {
+			color.Printf("%s%s%s %s  %s", indent, connector, swatch(node.record.Color), node.name, pluralize(node.record.Files, "file"))
+			printLabelDetails(*node.record)
+		}
+
+		if top {
+			// Line up the branches with the names, past the swatch
+			childIndent = "  "
+		}
+		printLabelTree(node.children, indent+childIndent, false)
+	}
+}
+
+var listLabels = &cli.Command{
+	Name:    "labels",
+	Usage:   "List labels and saved searches with the amount of files attached to them",
+	Aliases: []string{"ls"},
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "orphans",
+			Aliases: []string{"o"},
+			Usage:   "Only list labels which aren't attached to any files",
+		},
+		&cli.StringFlag{
+			Name:  "sort",
+			Usage: "Sort the labels by: name or usage",
+			Value: labelSortName,
+		},
+		&cli.BoolFlag{
+			Name:    "reverse",
+			Aliases: []string{"r"},
+			Usage:   "Reverse the sort order",
+		},
+		&cli.BoolFlag{
+			Name:    "tree",
+			Aliases: []string{"t"},
+			Usage:   "Render nested labels as a tree",
+		},
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   "Output format: text or json",
+			Value:   formatText,
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		format := ctx.String("format")
+		if format != formatText && format != formatJSON {
+			return fmt.Errorf("%w: %s", ErrInvalidFormat, format)
+		}
+
+		records, err := labelUsageRecords(db, ctx.Bool("orphans"))
+		if err != nil {
+			return err
+		}
+
+		var less func(a, b labelUsageRecord) bool
+		switch ctx.String("sort") {
+		case labelSortName:
+			less = func(a, b labelUsageRecord) bool { return a.Name < b.Name }
+		case labelSortUsage:
+			less = func(a, b labelUsageRecord) bool {
+				if a.Files == b.Files {
+					return a.Name < b.Name
+				}
+				return a.Files > b.Files
+			}
+		default:
+			return fmt.Errorf("%w: %s", database.ErrInvalidSort, ctx.String("sort"))
+		}
+
+		reverse := ctx.Bool("reverse")
+		sort.SliceStable(records, func(i, j int) bool {
+			if reverse {
+				return less(records[j], records[i])
+			}
+			return less(records[i], records[j])
+		})
+
+		if format == formatJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(records)
+		}
+
+		if ctx.Bool("tree") {
+			printLabelTree(buildLabelTree(records), "", true)
+			return nil
+		}
+
+		// Pad manually, as the escape codes of the swatches would throw off a tabwriter
+		width := 0
+		for _, rec := range records {
+			if n := utf8.RuneCountInString(rec.Name); n > width {
+				width = n
+			}
+		}
+
+		for _, rec := range records {
+			pad := strings.Repeat(" ", width-utf8.RuneCountInString(rec.Name))
+			color.Printf("%s %s%s  %s", swatch(rec.Color), rec.Name, pad, pluralize(rec.Files, "file"))
+			printLabelDetails(rec)
+		}
+
+		return nil
+	},
+}
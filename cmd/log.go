@@ -0,0 +1,169 @@
+package labee
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+)
+
+type historyRecord struct {
+	Id       int64                `json:"id"`
+	Op       string               `json:"op"`
+	Args     database.HistoryArgs `json:"args"`
+	At       string               `json:"at"`
+	User     string               `json:"user,omitempty"`
+	UndoneAt string               `json:"undoneAt,omitempty"`
+}
+
+// describeChange summarizes the arguments of a history entry in a single line
+func describeChange(entry database.HistoryEntry) string {
+	args := entry.Args
+	files := strings.Join(args.Files, ", ")
+	labels := strings.Join(args.Labels, ", ")
+
+	switch entry.Op {
+	case database.OpAddFiles:
+		if len(labels) == 0 {
+			return files
+		}
+		return labels + " to " + files
+	case database.OpDeleteFiles:
+		return files
+	case database.OpUpdateLabel:
+		changes := []string{}
+		if len(args.Name) > 0 {
+			changes = append(changes, "name "+args.Name)
+		}
+		if len(args.Color) > 0 {
+			changes = append(changes, "color "+args.Color)
+		}
+		return labels + ": " + strings.Join(changes, ", ")
+	case database.OpDeleteLabel:
+		if len(files) > 0 {
+			return labels + " from " + files
+		}
+	case database.OpAddAliases, database.OpDeleteAliases:
+		if len(labels) == 0 {
+			return strings.Join(args.Aliases, ", ")
+		}
+		return labels + ": " + strings.Join(args.Aliases, ", ")
+	case database.OpAddImplications, database.OpDeleteImplications:
+		return labels + " -> " + strings.Join(args.Implied, ", ")
+	case database.OpMergeDuplicates:
+		if len(args.Files) > 1 {
+			return strings.Join(args.Files[1:], ", ") + " onto " + args.Files[0]
+		}
+	case database.OpMoveFiles, database.OpRelinkFiles:
+		moves := []string{}
+		for i := 0; i+1 < len(args.Files); i += 2 {
+			moves = append(moves, args.Files[i]+" -> "+args.Files[i+1])
+		}
+		return strings.Join(moves, ", ")
+	case database.OpSetNote:
+		return files
+	case database.OpSaveSearch, database.OpDeleteSearch:
+		return database.SavedSearchPrefix + args.Search
+	}
+
+	return labels
+}
+
+var showLog = &cli.Command{
+	Name:  "log",
+	Usage: "Show the changes made to files and labels, latest first",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "file",
+			Usage: "Only changes to the file, or to anything beneath the directory",
+		},
+		&cli.StringFlag{
+			Name:    "label",
+			Aliases: []string{"l"},
+			Usage:   "Only changes to the label or its links",
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Usage: "Only changes made since the time, e.g. 12h, 7d, yesterday or 2026-01-01",
+		},
+		&cli.StringFlag{
+			Name:  "before",
+			Usage: "Only changes made before the time",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "Amount of changes to show, 0 for all of them",
+			Value: 20,
+		},
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   "Output format: text or json",
+			Value:   formatText,
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		format := ctx.String("format")
+		if format != formatText && format != formatJSON {
+			return fmt.Errorf("%w: %s", ErrInvalidFormat, format)
+		}
+
+		filter := database.HistoryFilter{
+			Label:  ctx.String("label"),
+			Since:  ctx.String("since"),
+			Before: ctx.String("before"),
+			Limit:  ctx.Int("limit"),
+		}
+		if ctx.IsSet("file") {
+			filter.File, err = filepath.Abs(ctx.String("file"))
+			if err != nil {
+				return err
+			}
+		}
+
+		entries, err := db.GetHistory(filter)
+		if err != nil {
+			return err
+		}
+
+		if format == formatJSON {
+			records := []historyRecord{}
+			for _, e := range entries {
+				records = append(records, historyRecord{
+					Id:       e.Id,
+					Op:       e.Op,
+					Args:     e.Args,
+					At:       formatTime(e.At, time.RFC3339),
+					User:     e.User,
+					UndoneAt: formatTime(e.UndoneAt, time.RFC3339),
+				})
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(records)
+		}
+
+		for _, e := range entries {
+			undone := ""
+			if e.UndoneAt != 0 {
+				undone = " <yellow>(undone)</>"
+			}
+			color.Printf("<gray>%s</> %-8s <cyan>%-12s</> %s%s\n",
+				formatTime(e.At, timeLayout), e.User, e.Op, describeChange(e), undone)
+		}
+
+		return nil
+	},
+}
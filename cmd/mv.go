@@ -0,0 +1,63 @@
+package labee
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/urfave/cli/v2"
+)
+
+var moveFile = &cli.Command{
+	Name:      "mv",
+	Usage:     "Move a file or directory on disk, along with its stored path and the paths beneath it",
+	ArgsUsage: "[SOURCE] [DESTINATION]",
+	Flags:     []cli.Flag{flagQuiet},
+	Action: func(ctx *cli.Context) error {
+		if ctx.Args().Len() != 2 {
+			return errors.New("please provide a source and a destination")
+		}
+
+		from, err := filepath.Abs(ctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+
+		to, err := filepath.Abs(ctx.Args().Get(1))
+		if err != nil {
+			return err
+		}
+
+		if _, err := os.Lstat(from); err != nil {
+			return err
+		}
+
+		// Like mv, moving into a directory keeps the name
+		if stat, err := os.Stat(to); err == nil && stat.IsDir() {
+			to = filepath.Join(to, filepath.Base(from))
+		}
+
+		if _, err := os.Lstat(to); err == nil {
+			return fmt.Errorf("%s already exists", to)
+		}
+
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		changed, err := db.MoveFiles(ctx.Context, from, to, os.Rename)
+		if err != nil {
+			return err
+		}
+
+		if !quiet {
+			log.Printf("%s moved to %s, %s updated", from, to, pluralize(int(changed), "stored path"))
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,128 @@
+package labee
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/urfave/cli/v2"
+)
+
+// editText opens the text in $EDITOR and returns the result
+func editText(text string) (string, error) {
+	editor := os.Getenv("EDITOR")
+	if len(editor) == 0 {
+		editor = "vi"
+	}
+
+	file, err := os.CreateTemp("", "labee-note-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(text)
+	if err != nil {
+		file.Close()
+		return "", err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	// $EDITOR may contain arguments, let the shell split them
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", file.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+var editNote = &cli.Command{
+	Name:      "note",
+	Usage:     "Attach a markdown note to a file. Opens $EDITOR, or reads the note from stdin",
+	ArgsUsage: "[FILE]",
+	Aliases:   []string{"n"},
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "clear",
+			Aliases: []string{"c"},
+			Usage:   "Remove the note",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		if !ctx.Args().Present() {
+			return ErrNoArgs
+		}
+
+		path, err := filepath.Abs(ctx.Args().First())
+		if err != nil {
+			return err
+		}
+
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		if ctx.Bool("clear") {
+			return db.SetNote(ctx.Context, path, "")
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			if _, err := db.GetFile(path); err != nil {
+				return err
+			}
+		}
+
+		note, err := db.GetNote(path)
+		if err != nil {
+			return err
+		}
+
+		var text string
+		if pipeArgsAvailable() {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			text = string(data)
+		} else {
+			text, err = editText(note)
+			if err != nil {
+				return err
+			}
+		}
+
+		text = string(bytes.TrimSpace([]byte(text)))
+		if text == note {
+			return nil
+		}
+
+		err = db.SetNote(ctx.Context, path, text)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("note of %s saved", path)
+
+		return nil
+	},
+}
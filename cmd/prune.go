@@ -0,0 +1,118 @@
+package labee
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+)
+
+var pruneFiles = &cli.Command{
+	Name:      "prune",
+	Usage:     "Remove stored files which no longer exist, optionally only beneath the path or with the labels",
+	ArgsUsage: "[PATH]",
+	Flags: []cli.Flag{
+		flagQuiet,
+		&cli.StringSliceFlag{
+			Name:    "labels",
+			Aliases: []string{"l"},
+			Usage:   "Only prune files with these comma separated labels",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Only show which files would be removed",
+		},
+		&cli.BoolFlag{
+			Name:    "yes",
+			Aliases: []string{"y"},
+			Usage:   "Don't ask for confirmation",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		filter := database.FileFilter{Labels: ctx.StringSlice("labels"), Missing: true}
+
+		// Labels may come with value conditions, e.g. priority=2
+		labels := []string{}
+		for _, l := range filter.Labels {
+			labels = append(labels, database.LabelName(l))
+		}
+		if err := doLabelsExist(db, labels); err != nil {
+			return err
+		}
+
+		if ctx.Args().Present() {
+			filter.PathPrefix, err = filepath.Abs(ctx.Args().First())
+			if err != nil {
+				return err
+			}
+		}
+
+		files, err := db.GetFilesWithFilter(filter)
+		if err != nil {
+			return err
+		}
+
+		if len(files) == 0 {
+			if !quiet {
+				fmt.Println("No missing files found")
+			}
+			return nil
+		}
+
+		paths := []string{}
+		ids := []int64{}
+		for _, f := range files {
+			paths = append(paths, f.Path)
+			ids = append(ids, f.Id)
+		}
+
+		orphans, err := db.GetOrphanedLabels(ids)
+		if err != nil {
+			return err
+		}
+
+		if !quiet || ctx.Bool("dry-run") {
+			for _, path := range paths {
+				color.Yellowln(path)
+			}
+
+			fmt.Printf("%s to remove\n", pluralize(len(paths), "missing file"))
+			if len(orphans) > 0 {
+				color.Printf("Labels left without files: %s (see 'labee labels --orphans')\n", colorLabels(orphans))
+			}
+		}
+
+		if ctx.Bool("dry-run") {
+			return nil
+		}
+
+		if !ctx.Bool("yes") {
+			ok, err := confirm("Remove them from the storage?")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+		}
+
+		err = db.DeleteFiles(ctx.Context, paths)
+		if err != nil {
+			return err
+		}
+
+		if !quiet {
+			log.Printf("%s removed", pluralize(len(paths), "file"))
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,73 @@
+package labee
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/urfave/cli/v2"
+)
+
+var refreshFiles = &cli.Command{
+	Name:      "refresh",
+	Usage:     "Record the current size, modification time, mode and MIME type of stored files, for 'find --size' and friends",
+	ArgsUsage: "[PATH...]",
+	Flags:     []cli.Flag{flagQuiet},
+	Action: func(ctx *cli.Context) error {
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		files, err := storedFilesUnder(db, ctx.Args().Slice())
+		if err != nil {
+			return err
+		}
+
+		refreshed, missing, err := db.RefreshFiles(ctx.Context, files)
+		if err != nil {
+			return err
+		}
+
+		if !quiet {
+			fmt.Printf("%d refreshed, %d missing\n", refreshed, missing)
+		}
+
+		return nil
+	},
+}
+
+// storedFilesUnder returns the stored files at or beneath the paths,
+// or every stored file when no paths are given
+func storedFilesUnder(db *database.DB, args []string) ([]database.File, error) {
+	prefixes := []string{""}
+	if len(args) > 0 {
+		prefixes = nil
+		for _, arg := range args {
+			path, err := filepath.Abs(arg)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, path)
+		}
+	}
+
+	var files []database.File
+	seen := map[int64]bool{}
+	for _, prefix := range prefixes {
+		found, err := db.GetFilesWithFilter(database.FileFilter{PathPrefix: prefix})
+		if err != nil {
+			return nil, err
+		}
+
+		// Nested paths select the same files
+		for _, f := range found {
+			if !seen[f.Id] {
+				seen[f.Id] = true
+				files = append(files, f)
+			}
+		}
+	}
+
+	return files, nil
+}
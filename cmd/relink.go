@@ -0,0 +1,74 @@
+package labee
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/urfave/cli/v2"
+)
+
+var relinkFiles = &cli.Command{
+	Name:      "relink",
+	Usage:     "Search the directories for missing stored files which were moved, and store their new paths. Searches the current directory by default",
+	ArgsUsage: "[SEARCH_ROOT...]",
+	Flags: []cli.Flag{
+		flagQuiet,
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Only show which files would be relinked",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		roots := []string{"."}
+		if ctx.Args().Present() {
+			roots = ctx.Args().Slice()
+		}
+		for i, root := range roots {
+			roots[i], err = filepath.Abs(root)
+			if err != nil {
+				return err
+			}
+		}
+
+		moved, ambiguous, err := db.FindMovedFiles(roots)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range ambiguous {
+			log.Printf("%s may have been moved to any of: %s", f.Path, strings.Join(f.Paths, ", "))
+		}
+
+		if !quiet {
+			for _, f := range moved {
+				fmt.Printf("%s -> %s (by %s)\n", f.Path, f.Paths[0], f.By)
+			}
+		}
+
+		if ctx.Bool("dry-run") {
+			if !quiet {
+				fmt.Printf("%s would be relinked\n", pluralize(len(moved), "file"))
+			}
+			return nil
+		}
+
+		err = db.RelinkFiles(ctx.Context, moved)
+		if err != nil {
+			return err
+		}
+
+		if !quiet {
+			fmt.Printf("%s relinked\n", pluralize(len(moved), "file"))
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,200 @@
+package labee
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+)
+
+func validSearchName(name string) (string, error) {
+	name = strings.TrimPrefix(name, database.SavedSearchPrefix)
+	if len(name) == 0 || strings.ContainsAny(name, ", \t\n()'\"") {
+		return "", fmt.Errorf("'%s' is not a valid search name", name)
+	}
+
+	return name, nil
+}
+
+// describeFilter formats a filter the way it would be passed to find
+func describeFilter(filter database.FileFilter) string {
+	var parts []string
+
+	if len(filter.Labels) > 0 {
+		parts = append(parts, "-l "+strings.Join(filter.Labels, ","))
+	}
+	if len(filter.Query) > 0 {
+		parts = append(parts, fmt.Sprintf("-q %q", filter.Query))
+	}
+	if filter.Exact {
+		parts = append(parts, "--exact")
+	}
+	if len(filter.Pattern) > 0 {
+		parts = append(parts, fmt.Sprintf("-n %q", filter.Pattern))
+	}
+	if filter.Regex {
+		parts = append(parts, "--regex")
+	}
+	if filter.IgnoreCase {
+		parts = append(parts, "--ignore-case")
+	}
+	if filter.Basename {
+		parts = append(parts, "--basename")
+	}
+	if len(filter.Contains) > 0 {
+		parts = append(parts, fmt.Sprintf("-c %q", filter.Contains))
+	}
+	if len(filter.Note) > 0 {
+		parts = append(parts, fmt.Sprintf("--note %q", filter.Note))
+	}
+	if filter.Missing {
+		parts = append(parts, "--missing")
+	}
+	if filter.Existing {
+		parts = append(parts, "--existing")
+	}
+	if len(filter.AddedSince) > 0 {
+		parts = append(parts, "--added-since "+filter.AddedSince)
+	}
+	if len(filter.AddedBefore) > 0 {
+		parts = append(parts, "--added-before "+filter.AddedBefore)
+	}
+	if len(filter.Size) > 0 {
+		parts = append(parts, "--size "+filter.Size)
+	}
+	if len(filter.ModifiedSince) > 0 {
+		parts = append(parts, "--modified-since "+filter.ModifiedSince)
+	}
+	if len(filter.Type) > 0 {
+		parts = append(parts, "--type "+filter.Type)
+	}
+	if len(filter.Mime) > 0 {
+		parts = append(parts, fmt.Sprintf("--mime %q", filter.Mime))
+	}
+	if filter.Untagged {
+		parts = append(parts, "--untagged")
+	}
+	if filter.Walk {
+		parts = append(parts, "--walk")
+	}
+	if len(filter.Sort) > 0 && filter.Sort != database.SortPath {
+		parts = append(parts, "--sort "+filter.Sort)
+	}
+	if filter.Reverse {
+		parts = append(parts, "--reverse")
+	}
+	if filter.Limit > 0 {
+		parts = append(parts, fmt.Sprintf("--limit %d", filter.Limit))
+	}
+	if filter.Offset > 0 {
+		parts = append(parts, fmt.Sprintf("--offset %d", filter.Offset))
+	}
+	if len(filter.PathPrefix) > 0 {
+		parts = append(parts, filter.PathPrefix)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+var (
+	saveSearch = &cli.Command{
+		Name:      "save",
+		Usage:     "Save a search under a name. Use it as a label via '@name'",
+		ArgsUsage: "[name] [PATH]",
+		Aliases:   []string{"s"},
+		Flags:     fileFilterFlags,
+		Action: func(ctx *cli.Context) error {
+			if !ctx.Args().Present() {
+				return ErrNoArgs
+			}
+
+			name, err := validSearchName(ctx.Args().First())
+			if err != nil {
+				return err
+			}
+
+			db, err := database.FromContext(ctx.Context)
+			if err != nil {
+				return err
+			}
+
+			filter, err := fileFilterFromContext(ctx, db, ctx.Args().Get(1))
+			if err != nil {
+				return err
+			}
+
+			err = db.SaveSearch(ctx.Context, name, filter)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Search saved as %s%s\n", database.SavedSearchPrefix, name)
+
+			return nil
+		},
+	}
+
+	listSearches = &cli.Command{
+		Name:    "list",
+		Usage:   "List saved searches",
+		Aliases: []string{"l"},
+		Action: func(ctx *cli.Context) error {
+			db, err := database.FromContext(ctx.Context)
+			if err != nil {
+				return err
+			}
+
+			searches, err := db.GetAllSavedSearches()
+			if err != nil {
+				return err
+			}
+
+			for _, s := range searches {
+				color.Tag("us").Print(database.SavedSearchPrefix + s.Name)
+				fmt.Println(" " + describeFilter(s.Filter))
+			}
+
+			return nil
+		},
+	}
+
+	removeSearch = &cli.Command{
+		Name:      "remove",
+		Usage:     "Remove saved searches",
+		ArgsUsage: "[name...]",
+		Aliases:   []string{"r"},
+		Action: func(ctx *cli.Context) error {
+			if !ctx.Args().Present() {
+				return ErrNoArgs
+			}
+
+			db, err := database.FromContext(ctx.Context)
+			if err != nil {
+				return err
+			}
+
+			var errs error
+			for _, arg := range ctx.Args().Slice() {
+				name := strings.TrimPrefix(arg, database.SavedSearchPrefix)
+				errs = errors.Join(errs, db.DeleteSavedSearch(ctx.Context, name))
+			}
+
+			return errs
+		},
+	}
+
+	search = &cli.Command{
+		Name:      "search",
+		Usage:     "Manage saved searches",
+		ArgsUsage: "[subcommand]",
+		Aliases:   []string{"s"},
+		Subcommands: []*cli.Command{
+			saveSearch,
+			listSearches,
+			removeSearch,
+		},
+	}
+)
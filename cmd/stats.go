@@ -0,0 +1,176 @@
+package labee
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+)
+
+// Width of the longest bar in the files per label histogram
+const histogramWidth = 30
+
+type labelCountRecord struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+	Files int    `json:"files"`
+}
+
+type databaseRecord struct {
+	Path          string `json:"path"`
+	Size          int64  `json:"size"`
+	SchemaVersion int    `json:"schemaVersion"`
+}
+
+type statsRecord struct {
+	*database.Stats
+	LabelFiles []labelCountRecord `json:"labelFiles"`
+	Database   databaseRecord     `json:"database"`
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func printStats(stats statsRecord) {
+	title := color.Tag("us")
+
+	fmt.Printf("Files:  %d", stats.Files)
+	if stats.Missing > 0 || stats.Untagged > 0 {
+		fmt.Print(" (")
+		color.Yellow.Printf("%d missing", stats.Missing)
+		fmt.Printf(", %d untagged)", stats.Untagged)
+	}
+	fmt.Println()
+	fmt.Printf("Labels: %d\n", stats.Labels)
+	fmt.Printf("Links:  %d\n", stats.Links)
+
+	if len(stats.LabelFiles) > 0 {
+		fmt.Println()
+		title.Println("Files per label")
+
+		width, most := 0, 0
+		for _, l := range stats.LabelFiles {
+			if n := utf8.RuneCountInString(l.Name); n > width {
+				width = n
+			}
+			if l.Files > most {
+				most = l.Files
+			}
+		}
+
+		for _, l := range stats.LabelFiles {
+			bar := 0
+			if most > 0 {
+				bar = l.Files * histogramWidth / most
+			}
+
+			name, _ := colorize(l.Name, l.Color)
+			pad := strings.Repeat(" ", width-utf8.RuneCountInString(l.Name))
+			color.Printf("%s%s %s %d\n", name, pad, strings.Repeat("█", bar), l.Files)
+		}
+	}
+
+	if len(stats.Directories) > 0 {
+		fmt.Println()
+		title.Println("Top directories")
+		for _, d := range stats.Directories {
+			fmt.Printf("%6d  %s\n", d.Files, d.Path)
+		}
+	}
+
+	fmt.Println()
+	title.Println("Storage")
+	fmt.Printf("%s, %s, schema v%d\n", stats.Database.Path, formatSize(stats.Database.Size), stats.Database.SchemaVersion)
+}
+
+var showStats = &cli.Command{
+	Name:  "stats",
+	Usage: "Print an overview of the storage",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "top",
+			Usage: "Amount of directories to list",
+			Value: 10,
+		},
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   "Output format: text or json",
+			Value:   formatText,
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		format := ctx.String("format")
+		if format != formatText && format != formatJSON {
+			return fmt.Errorf("%w: %s", ErrInvalidFormat, format)
+		}
+
+		if ctx.Int("top") < 0 {
+			return fmt.Errorf("--top can't be negative: %d", ctx.Int("top"))
+		}
+
+		stats, err := db.GetStats(ctx.Context, ctx.Int("top"))
+		if err != nil {
+			return err
+		}
+
+		record := statsRecord{Stats: stats, LabelFiles: []labelCountRecord{}}
+
+		usage, err := db.GetLabelUsage()
+		if err != nil {
+			return err
+		}
+
+		for _, u := range usage {
+			l := labelCountRecord{Name: u.Name, Files: u.Files}
+			if u.Color != colorNone {
+				l.Color = u.Color
+			}
+			record.LabelFiles = append(record.LabelFiles, l)
+		}
+
+		record.Database.Path = db.Path()
+		record.Database.SchemaVersion, err = db.SchemaVersion(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		stat, err := os.Stat(db.Path())
+		if err != nil {
+			return err
+		}
+		record.Database.Size = stat.Size()
+
+		if format == formatJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(record)
+		}
+
+		printStats(record)
+
+		return nil
+	},
+}
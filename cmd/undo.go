@@ -0,0 +1,49 @@
+package labee
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/LeBulldoge/labee/internal/database"
+	"github.com/gookit/color"
+	"github.com/urfave/cli/v2"
+)
+
+var undoChanges = &cli.Command{
+	Name:      "undo",
+	Usage:     "Revert the latest N changes shown by 'labee log', restoring removed files, labels and links and moving files back",
+	ArgsUsage: "[N]",
+	Flags: []cli.Flag{
+		flagQuiet,
+	},
+	Action: func(ctx *cli.Context) error {
+		db, err := database.FromContext(ctx.Context)
+		if err != nil {
+			return err
+		}
+
+		n := 1
+		if ctx.Args().Present() {
+			n, err = strconv.Atoi(ctx.Args().First())
+			if err != nil || n < 1 {
+				return fmt.Errorf("'%s' is not a positive number of changes", ctx.Args().First())
+			}
+		}
+
+		undone, err := db.UndoChanges(ctx.Context, n, os.Rename)
+		if err != nil {
+			return err
+		}
+
+		if !quiet {
+			for _, e := range undone {
+				color.Printf("<gray>%s</> <cyan>%s</> %s\n", formatTime(e.At, timeLayout), e.Op, describeChange(e))
+			}
+			log.Printf("%s undone", pluralize(len(undone), "change"))
+		}
+
+		return nil
+	},
+}
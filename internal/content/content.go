@@ -0,0 +1,67 @@
+package content
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Files larger than this are not indexed
+const MaxSize = 8 << 20
+
+var (
+	ErrUnsupported = errors.New("unsupported file type")
+	ErrTooLarge    = errors.New("file is too large")
+)
+
+type extractor func(data []byte) (string, error)
+
+var extractors = map[string]extractor{
+	".txt":      plainText,
+	".text":     plainText,
+	".md":       plainText,
+	".markdown": plainText,
+}
+
+// Supported reports whether text can be extracted from the file
+func Supported(path string) bool {
+	_, ok := extractors[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// Extract returns the searchable text of the file
+func Extract(path string) (string, error) {
+	extract, ok := extractors[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupported, path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxSize+1))
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) > MaxSize {
+		return "", fmt.Errorf("%w: %s", ErrTooLarge, path)
+	}
+
+	return extract(data)
+}
+
+func plainText(data []byte) (string, error) {
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("%w: not valid utf-8", ErrUnsupported)
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type LabelAlias struct {
+	Name  string `db:"name"`
+	Label string `db:"label"`
+}
+
+var (
+	ErrLabelIsAlias  = errors.New("name is already an alias")
+	ErrAliasNotFound = errors.New("alias does not exist")
+	ErrAliasOfItself = errors.New("label can't be an alias of itself")
+	ErrAliasIsLabel  = errors.New("alias is already a label")
+)
+
+func isLabelAlias(db sqlx.Queryer, name string) bool {
+	var id int64
+	err := sqlx.Get(db, &id, `SELECT labelId FROM LabelAlias WHERE name = ?`, name)
+	return err == nil
+}
+
+// canonicalLabelName returns the name of the label the alias refers to,
+// or the name itself if it isn't an alias
+func canonicalLabelName(db sqlx.Queryer, name string) string {
+	var canonical string
+	err := sqlx.Get(db, &canonical,
+		`SELECT Label.name FROM LabelAlias
+    JOIN Label ON Label.id = LabelAlias.labelId
+    WHERE LabelAlias.name = ?`,
+		name)
+	if err != nil {
+		return name
+	}
+
+	return canonical
+}
+
+// AddLabelAliases makes the aliases refer to the label, creating it if needed.
+// Existing labels named like one of the aliases are merged into the label if merge
+// is set, otherwise they're refused. Returns the names of the merged labels.
+func (m *DB) AddLabelAliases(ctx context.Context, name string, aliases []string, merge bool) ([]string, error) {
+	var merged []string
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		// Links of the merged labels move to the label
+		links, err := labelFilesScope(ctx, tx, aliases)
+		if err != nil {
+			return err
+		}
+
+		changes, err := trackChanges(ctx, tx, labelScope, labelAliasScope, labelImplicationScope, links)
+		if err != nil {
+			return err
+		}
+
+		label, err := getOrInsertLabel(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		for _, alias := range aliases {
+			if alias == label.Name {
+				return fmt.Errorf("%w: %s", ErrAliasOfItself, alias)
+			}
+
+			var id int64
+			err := tx.GetContext(ctx, &id, `SELECT id FROM Label WHERE name = ?`, alias)
+			if err == nil {
+				if !merge {
+					return fmt.Errorf("%w: %s", ErrAliasIsLabel, alias)
+				}
+
+				err = mergeLabel(ctx, tx, id, label.Id)
+				if err != nil {
+					return err
+				}
+				merged = append(merged, alias)
+			}
+
+			_, err = tx.ExecContext(ctx,
+				`INSERT INTO LabelAlias (name, labelId) VALUES ($1, $2)
+        ON CONFLICT(name) DO UPDATE SET labelId=excluded.labelId`,
+				alias, label.Id)
+			if err != nil {
+				return err
+			}
+		}
+
+		return recordHistory(ctx, tx, OpAddAliases, HistoryArgs{Labels: []string{name}, Aliases: aliases}, changes)
+	})
+
+	return merged, err
+}
+
+// mergeLabel moves the links, aliases and implication rules of one label to another and deletes it.
+// Values already present on the target links are kept.
+func mergeLabel(ctx context.Context, tx *sqlx.Tx, fromId int64, toId int64) error {
+	stmts := []string{
+		`INSERT OR IGNORE INTO FileInfo (fileId, labelId, value, linked_at)
+    SELECT fileId, $2, value, linked_at FROM FileInfo WHERE labelId = $1`,
+		`DELETE FROM FileInfo WHERE labelId = $1`,
+		`UPDATE LabelAlias SET labelId = $2 WHERE labelId = $1`,
+		`UPDATE OR IGNORE LabelImplication SET labelId = $2 WHERE labelId = $1`,
+		`UPDATE OR IGNORE LabelImplication SET impliedId = $2 WHERE impliedId = $1`,
+		`DELETE FROM LabelImplication WHERE labelId = impliedId`,
+		`DELETE FROM Label WHERE id = $1`,
+	}
+
+	for _, stmt := range stmts {
+		_, err := tx.ExecContext(ctx, stmt, fromId, toId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *DB) DeleteLabelAliases(ctx context.Context, aliases []string) error {
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		changes, err := trackChanges(ctx, tx, labelAliasScope)
+		if err != nil {
+			return err
+		}
+
+		for _, alias := range aliases {
+			res, err := tx.ExecContext(ctx, `DELETE FROM LabelAlias WHERE name = ?`, alias)
+			if err != nil {
+				return err
+			}
+
+			if cnt, err := res.RowsAffected(); err != nil {
+				return err
+			} else if cnt == 0 {
+				return fmt.Errorf("%w: %s", ErrAliasNotFound, alias)
+			}
+		}
+
+		return recordHistory(ctx, tx, OpDeleteAliases, HistoryArgs{Aliases: aliases}, changes)
+	})
+
+	return err
+}
+
+// GetLabelAliases returns every alias, ordered by the names of their labels
+func (m *DB) GetLabelAliases() ([]LabelAlias, error) {
+	stmt := `SELECT LabelAlias.name, Label.name AS label
+    FROM LabelAlias
+    JOIN Label ON Label.id = LabelAlias.labelId
+    ORDER BY Label.name, LabelAlias.name`
+
+	aliases := []LabelAlias{}
+	err := m.db.Select(&aliases, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
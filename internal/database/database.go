@@ -12,7 +12,8 @@ import (
 )
 
 type DB struct {
-	db *sqlx.DB
+	db   *sqlx.DB
+	path string
 }
 
 func New(ctx context.Context) (*DB, error) {
@@ -55,11 +56,27 @@ func New(ctx context.Context) (*DB, error) {
 		return nil, err
 	}
 
-	res := &DB{db: db}
+	res := &DB{db: db, path: dbPath}
 
 	return res, nil
 }
 
+// Path returns the location of the database file
+func (m *DB) Path() string {
+	return m.path
+}
+
+func (m *DB) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		var err error
+		version, err = schema.CurrentVersion(ctx, tx)
+		return err
+	})
+
+	return version, err
+}
+
 func (m *DB) Close() error {
 	_, err := m.db.Exec("PRAGMA optimize")
 	if err != nil {
@@ -85,9 +102,24 @@ func tx(ctx context.Context, db *sqlx.DB, f func(context.Context, *sqlx.Tx) erro
 	return tx.Commit()
 }
 
-func insertFileInfo(tx *sqlx.Tx, fileId int64, labelIds []int64) error {
-	for _, labelId := range labelIds {
-		_, err := tx.Exec(`INSERT OR IGNORE INTO FileInfo (fileId, labelId) VALUES ($1, $2)`, fileId, labelId)
+// A label to attach, along with its value. Value is nil if none was assigned.
+type labelLink struct {
+	id    int64
+	value any
+}
+
+func insertFileInfo(tx *sqlx.Tx, fileId int64, links []labelLink) error {
+	for _, link := range links {
+		var err error
+		if link.value == nil {
+			_, err = tx.Exec(`INSERT OR IGNORE INTO FileInfo (fileId, labelId) VALUES ($1, $2)`, fileId, link.id)
+		} else {
+			_, err = tx.Exec(
+				`INSERT INTO FileInfo (fileId, labelId, value) VALUES ($1, $2, $3)
+        ON CONFLICT(fileId, labelId) DO UPDATE SET value=excluded.value`,
+				fileId, link.id, link.value,
+			)
+		}
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,251 @@
+package database
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/LeBulldoge/labee/internal/os"
+)
+
+// Labels of a directory are inherited by everything beneath it.
+// Owner is the directory the label is attached to. Files stored without
+// metadata have no mode, so they may be directories as well.
+var inheritedByExpr = fmt.Sprintf(
+	"(Owner.mode & %[2]d != 0 OR Owner.modified_at = 0) AND substr(File.path, 1, length(rtrim(Owner.path, '%[1]c')) + 1) = rtrim(Owner.path, '%[1]c') || '%[1]c'",
+	filepath.Separator, uint32(fs.ModeDir),
+)
+
+// Links of the files, along with their owners
+const ownedLinks = `FileInfo
+    JOIN Label ON Label.id = FileInfo.labelId
+    JOIN File AS Owner ON Owner.id = FileInfo.fileId`
+
+// ownedCondition matches the files with a link satisfying cond, either attached
+// to the file itself or inherited from one of its directories. The links of the
+// file are looked up by their key, apart from the prefix matching of directories.
+// Returns the arguments of cond once for each case.
+func ownedCondition(cond string, args ...any) (string, []any) {
+	expr := `(EXISTS (SELECT 1 FROM FileInfo JOIN Label ON Label.id = FileInfo.labelId
+    WHERE FileInfo.fileId = File.id AND ` + cond + `)
+    OR EXISTS (SELECT 1 FROM ` + ownedLinks + `
+    WHERE ` + inheritedByExpr + " AND " + cond + "))"
+
+	return expr, append(append([]any{}, args...), args...)
+}
+
+// A label attached to one of the directories above a file
+type InheritedLabel struct {
+	Label
+	// Path of the directory the label is attached to
+	From string `db:"owner"`
+}
+
+// GetInheritedLabels returns the labels of every stored directory above the path,
+// closest directory first. The path itself doesn't have to be stored.
+func (m *DB) GetInheritedLabels(path string) ([]InheritedLabel, error) {
+	stmt := fmt.Sprintf(
+		`SELECT Label.*, FileInfo.value, Owner.path AS owner FROM %[1]s
+    WHERE Owner.path != $1
+    AND substr($1, 1, length(rtrim(Owner.path, '%[2]c')) + 1) = rtrim(Owner.path, '%[2]c') || '%[2]c'
+    ORDER BY length(Owner.path) DESC, Label.name`,
+		ownedLinks, filepath.Separator,
+	)
+
+	labels := []InheritedLabel{}
+	err := m.db.Select(&labels, stmt, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
+
+// walkFilesWithFilter returns the stored files matching the filter, along with
+// every untracked file on disk beneath the matching directories.
+//
+// An untracked file has exactly the labels of its closest stored directory,
+// so it matches the label conditions whenever that directory does.
+func (m *DB) walkFilesWithFilter(filter FileFilter) ([]File, error) {
+	switch filter.Sort {
+	case "", SortPath, SortName:
+	default:
+		return nil, fmt.Errorf("%w: walked files can only be sorted by path or name", ErrInvalidSort)
+	}
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit and offset can't be negative", ErrInvalidSort)
+	}
+
+	stored := filter
+	stored.Walk = false
+	stored.Limit = 0
+	stored.Offset = 0
+
+	files, err := m.GetFilesWithFilter(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	// Untracked files have no notes, contents or recorded metadata, and are never missing
+	hasMeta := len(filter.Size) > 0 || len(filter.ModifiedSince) > 0 || len(filter.Type) > 0 || len(filter.Mime) > 0
+	if len(filter.Note) == 0 && len(filter.Contains) == 0 && !filter.Missing && !hasMeta {
+		walked, err := m.walkMatchingDirectories(filter)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, walked...)
+	}
+
+	less := func(a, b File) bool { return a.Path < b.Path }
+	if filter.Sort == SortName {
+		less = func(a, b File) bool {
+			if an, bn := filepath.Base(a.Path), filepath.Base(b.Path); an != bn {
+				return an < bn
+			}
+			return a.Path < b.Path
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if filter.Reverse {
+			return less(files[j], files[i])
+		}
+		return less(files[i], files[j])
+	})
+
+	if filter.Offset >= len(files) {
+		return []File{}, nil
+	}
+	files = files[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(files) {
+		files = files[:filter.Limit]
+	}
+
+	return files, nil
+}
+
+func (m *DB) walkMatchingDirectories(filter FileFilter) ([]File, error) {
+	matchPath, err := pathMatcher(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Path conditions apply to the walked files, not the directories
+	dirFilter := filter
+	dirFilter.Walk = false
+	dirFilter.Pattern = ""
+	dirFilter.PathPrefix = ""
+	dirFilter.Sort = SortPath
+	dirFilter.Reverse = false
+	dirFilter.Limit = 0
+	dirFilter.Offset = 0
+
+	matching, err := m.GetFilesWithFilter(dirFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := map[string]bool{}
+	for _, f := range matching {
+		matched[f.Path] = true
+	}
+
+	tracked := map[string]bool{}
+	paths := []string{}
+	err = m.db.Select(&paths, `SELECT path FROM File`)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		tracked[p] = true
+	}
+
+	files := []File{}
+	roots := []string{}
+	for _, dir := range matching {
+		// Directories nested in walked ones are walked along with them
+		if isBeneathAny(dir.Path, roots) {
+			continue
+		}
+		if dir.Deleted || !os.IsDir(dir.Path) {
+			continue
+		}
+		if len(filter.PathPrefix) > 0 && !strings.HasPrefix(dir.Path, filter.PathPrefix) && !isBeneath(filter.PathPrefix, dir.Path) {
+			continue
+		}
+		root := dir.Path
+		roots = append(roots, root)
+
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || path == root {
+				// Unreadable entries are skipped
+				return nil
+			}
+
+			if tracked[path] {
+				// Stored files are already filtered by the query
+				if d.IsDir() && !matched[path] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !d.IsDir() && matchPath(path) {
+				files = append(files, File{Path: path})
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// pathMatcher reports whether a path passes the path conditions of the filter
+func pathMatcher(filter FileFilter) (func(string) bool, error) {
+	var re *regexp.Regexp
+	if len(filter.Pattern) > 0 {
+		var err error
+		re, err = compileRegexp(patternRegexp(filter))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPattern, err)
+		}
+	}
+
+	return func(path string) bool {
+		if len(filter.PathPrefix) > 0 && !strings.HasPrefix(path, filter.PathPrefix) {
+			return false
+		}
+
+		if re == nil {
+			return true
+		}
+
+		if filter.Basename {
+			return re.MatchString(filepath.Base(path))
+		}
+		return re.MatchString(path)
+	}, nil
+}
+
+// isBeneath reports whether path is inside the directory dir
+func isBeneath(path string, dir string) bool {
+	return strings.HasPrefix(path, strings.TrimRight(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+func isBeneathAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if isBeneath(path, dir) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,291 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var ErrInvalidQuery = errors.New("invalid label query")
+
+// A parsed label expression, e.g. `work and (urgent or review) and not archived`
+type Query struct {
+	root labelExpr
+}
+
+type labelExpr interface {
+	build(b *filterBuilder) (string, error)
+}
+
+type andExpr struct {
+	left, right labelExpr
+}
+
+type orExpr struct {
+	left, right labelExpr
+}
+
+type notExpr struct {
+	expr labelExpr
+}
+
+func (e andExpr) build(b *filterBuilder) (string, error) {
+	return buildBinary(b, e.left, e.right, "AND")
+}
+
+func (e orExpr) build(b *filterBuilder) (string, error) {
+	return buildBinary(b, e.left, e.right, "OR")
+}
+
+func buildBinary(b *filterBuilder, left labelExpr, right labelExpr, op string) (string, error) {
+	l, err := left.build(b)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := right.build(b)
+	if err != nil {
+		return "", err
+	}
+
+	return "(" + l + " " + op + " " + r + ")", nil
+}
+
+func (e notExpr) build(b *filterBuilder) (string, error) {
+	s, err := e.expr.build(b)
+	if err != nil {
+		return "", err
+	}
+
+	return "NOT " + s, nil
+}
+
+func (e labelTerm) build(b *filterBuilder) (string, error) {
+	return b.labelCondition(e)
+}
+
+// Labels returns every label name mentioned in the query
+func (q *Query) Labels() []string {
+	var labels []string
+
+	var walk func(e labelExpr)
+	walk = func(e labelExpr) {
+		switch e := e.(type) {
+		case andExpr:
+			walk(e.left)
+			walk(e.right)
+		case orExpr:
+			walk(e.left)
+			walk(e.right)
+		case notExpr:
+			walk(e.expr)
+		case labelTerm:
+			labels = append(labels, e.name)
+		}
+	}
+	walk(q.root)
+
+	return labels
+}
+
+type tokenKind int
+
+const (
+	tokenLabel tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+	tokenEnd
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+	// Quoted labels are taken literally, without value comparisons
+	quoted bool
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, value: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, value: ")", pos: i})
+			i++
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated quote at %d", ErrInvalidQuery, i)
+			}
+
+			tokens = append(tokens, token{kind: tokenLabel, value: string(runes[i+1 : end]), pos: i, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '(' && runes[end] != ')' {
+				end++
+			}
+
+			word := string(runes[i:end])
+			kind := tokenLabel
+			switch strings.ToLower(word) {
+			case "and":
+				kind = tokenAnd
+			case "or":
+				kind = tokenOr
+			case "not":
+				kind = tokenNot
+			}
+
+			tokens = append(tokens, token{kind: kind, value: word, pos: i})
+			i = end
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEnd, pos: len(runes)})
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEnd {
+		p.pos++
+	}
+
+	return t
+}
+
+// ParseQuery parses a label expression. Supported operators in order of
+// precedence are `not`, `and`, `or`. Parentheses group subexpressions and
+// quotes allow labels containing spaces or keywords. Unquoted labels may be
+// compared against their values, e.g. `priority>=2` or `due<today`.
+func ParseQuery(s string) (*Query, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEnd {
+		return nil, fmt.Errorf("%w: empty expression", ErrInvalidQuery)
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEnd {
+		return nil, fmt.Errorf("%w: unexpected '%s' at %d", ErrInvalidQuery, t.value, t.pos)
+	}
+
+	return &Query{root: root}, nil
+}
+
+func (p *parser) parseOr() (labelExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenOr {
+		p.next()
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = orExpr{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (labelExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenAnd {
+		p.next()
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = andExpr{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (labelExpr, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return notExpr{expr: expr}, nil
+	}
+
+	return p.parseTerm()
+}
+
+func (p *parser) parseTerm() (labelExpr, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenLabel:
+		if t.quoted {
+			return labelTerm{name: t.value}, nil
+		}
+		return parseLabelTerm(t.value), nil
+	case tokenOpen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if c := p.next(); c.kind != tokenClose {
+			return nil, fmt.Errorf("%w: missing ')' at %d", ErrInvalidQuery, c.pos)
+		}
+
+		return expr, nil
+	case tokenEnd:
+		return nil, fmt.Errorf("%w: unexpected end of expression", ErrInvalidQuery)
+	default:
+		return nil, fmt.Errorf("%w: unexpected '%s' at %d", ErrInvalidQuery, t.value, t.pos)
+	}
+}
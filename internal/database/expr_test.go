@@ -0,0 +1,44 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query  string
+		labels []string
+		err    bool
+	}{
+		{query: "work", labels: []string{"work"}},
+		{query: "work and (urgent or review) and not archived", labels: []string{"work", "urgent", "review", "archived"}},
+		{query: `"and" OR 'two words'`, labels: []string{"and", "two words"}},
+		{query: "", err: true},
+		{query: "work and", err: true},
+		{query: "(work", err: true},
+		{query: "work)", err: true},
+		{query: "work urgent", err: true},
+		{query: `"work`, err: true},
+	}
+
+	for _, test := range tests {
+		q, err := ParseQuery(test.query)
+		if test.err {
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("query %q: expected ErrInvalidQuery, got %v", test.query, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("query %q: unexpected error: %v", test.query, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(q.Labels(), test.labels) {
+			t.Errorf("query %q: labels %v don't equal %v", test.query, q.Labels(), test.labels)
+		}
+	}
+}
@@ -5,22 +5,37 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
 
+	"github.com/LeBulldoge/labee/internal/fuzzy"
 	"github.com/LeBulldoge/labee/internal/os"
 	"github.com/jmoiron/sqlx"
 )
 
 type File struct {
-	Id      int64  `db:"id"`
-	Path    string `db:"path"`
+	Id   int64  `db:"id"`
+	Path string `db:"path"`
+	// Unix time, 0 for files added before it was recorded
+	AddedAt int64 `db:"added_at"`
+	FileMeta
 	Deleted bool
 }
 
 func (m *DB) DeleteFiles(ctx context.Context, paths []string) error {
 	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		ids := []int64{}
+		err := tx.SelectContext(ctx, &ids, storedFileIds, jsonList(paths))
+		if err != nil {
+			return err
+		}
+
+		changes, err := trackChanges(ctx, tx, storedFileScopes(ids)...)
+		if err != nil {
+			return err
+		}
+
 		for _, v := range paths {
 			err := deleteFile(ctx, tx, v)
 			if err != nil {
@@ -28,7 +43,13 @@ func (m *DB) DeleteFiles(ctx context.Context, paths []string) error {
 			}
 		}
 
-		return nil
+		// Foreign keys aren't enforced, so the links are removed here
+		_, err = tx.ExecContext(ctx, `DELETE FROM FileInfo WHERE fileId IN (`+listedIds+`)`, jsonList(ids))
+		if err != nil {
+			return err
+		}
+
+		return recordHistory(ctx, tx, OpDeleteFiles, HistoryArgs{Files: paths}, changes)
 	})
 
 	return err
@@ -72,6 +93,55 @@ func sortAndPickFirstFile(files []File, q string) File {
 	return files[0]
 }
 
+func (m *DB) GetFile(path string) (*File, error) {
+	var file File
+	err := m.db.Get(&file, `SELECT `+fileColumns+` FROM File WHERE path = $1`, path)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w with %s", ErrFilesNotFound, path)
+	} else if err != nil {
+		return nil, err
+	}
+
+	file.Deleted = !os.FileExists(file.Path)
+
+	return &file, nil
+}
+
+// GetSimilarFiles returns up to n stored files with names closest to the name of path, best first
+func (m *DB) GetSimilarFiles(path string, n int) []File {
+	files := []File{}
+	err := m.db.Select(&files, `SELECT id, path FROM File`)
+	if err != nil {
+		return nil
+	}
+
+	// Among files with the same name prefer those located closer to path
+	dir := filepath.Dir(path)
+	dirDistance := map[string]int{}
+	for _, f := range files {
+		d := filepath.Dir(f.Path)
+		if _, ok := dirDistance[d]; !ok {
+			dirDistance[d] = fuzzy.Distance(dir, d)
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return dirDistance[filepath.Dir(files[i].Path)] < dirDistance[filepath.Dir(files[j].Path)]
+	})
+
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = filepath.Base(f.Path)
+	}
+
+	similar := []File{}
+	for _, i := range fuzzy.Rank(filepath.Base(path), names, n) {
+		similar = append(similar, files[i])
+	}
+
+	return markDeletedFiles(similar)
+}
+
 func (m *DB) GetFiles(keywords []string) ([]File, error) {
 	stmt := `SELECT File.id, File.path FROM File`
 
@@ -132,65 +202,6 @@ func markDeletedFiles(files []File) []File {
 	return files
 }
 
-func (m *DB) GetFilesFilteredWithLabels(labels []string, pattern string, pathPrefix string) ([]File, error) {
-	stmt := `SELECT File.id, File.path
-      FROM File, Label
-      INNER JOIN FileInfo ON File.id  = FileInfo.fileId
-                         AND Label.id = FileInfo.labelId
-      WHERE %s
-    GROUP BY File.path
-    HAVING COUNT(File.path) = ` + strconv.Itoa(len(labels))
-
-	filters := []string{"Label.name IN ('" + strings.Join(labels, "','") + "')"}
-
-	if len(pattern) > 0 || len(pathPrefix) > 0 {
-		filters = append(filters, buildFilenameFilters(pattern, pathPrefix))
-	}
-
-	stmt = fmt.Sprintf(stmt, strings.Join(filters, " AND "))
-
-	files := []File{}
-	err := m.db.Select(&files, stmt)
-	if err != nil {
-		return nil, err
-	}
-
-	files = markDeletedFiles(files)
-
-	return files, nil
-}
-
-func (m *DB) GetFilesFiltered(pattern string, pathPrefix string) ([]File, error) {
-	stmt := `SELECT File.* FROM File`
-
-	if len(pattern) > 0 || len(pathPrefix) > 0 {
-		stmt += " WHERE " + buildFilenameFilters(pattern, pathPrefix)
-	}
-
-	files := []File{}
-	err := m.db.Select(&files, stmt)
-	if err != nil {
-		return nil, err
-	}
-
-	files = markDeletedFiles(files)
-
-	return files, nil
-}
-
-func buildFilenameFilters(pattern string, pathPrefix string) string {
-	var filterBuilder strings.Builder
-
-	filterBuilder.Grow(len(pattern) + len(pathPrefix))
-	filterBuilder.WriteString("path GLOB '")
-	filterBuilder.WriteString(pathPrefix)
-	filterBuilder.WriteString("*")
-	filterBuilder.WriteString(pattern)
-	filterBuilder.WriteString("'")
-
-	return filterBuilder.String()
-}
-
 var (
 	ErrFileAlreadyExists = errors.New("file already exists in storage")
 	ErrFilesNotFound     = errors.New("couldn't find files")
@@ -220,14 +231,29 @@ func getOrInsertFile(tx *sqlx.Tx, path string) (int64, error) {
 
 func (m *DB) AddFilesAndLinks(ctx context.Context, filepaths []string, labelNames []string) error {
 	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
-		var labelIds []int64
+		changes, err := trackChanges(ctx, tx, fileScope(filepaths), fileInfoScope(filepaths), labelScope)
+		if err != nil {
+			return err
+		}
+
+		var links []labelLink
 		for _, name := range labelNames {
+			name, value, err := splitLabelValue(name)
+			if err != nil {
+				return err
+			}
+
 			label, err := getOrInsertLabel(ctx, tx, name)
 			if err != nil {
 				return err
 			}
 
-			labelIds = append(labelIds, label.Id)
+			links = append(links, labelLink{id: label.Id, value: value})
+		}
+
+		links, err = withImpliedLinks(tx, links)
+		if err != nil {
+			return err
 		}
 
 		for _, file := range filepaths {
@@ -236,13 +262,21 @@ func (m *DB) AddFilesAndLinks(ctx context.Context, filepaths []string, labelName
 				return err
 			}
 
-			err = insertFileInfo(tx, fileId, labelIds)
+			// Paths which can't be read are stored without metadata
+			if meta, err := statFileMeta(file); err == nil {
+				err = updateFileMeta(ctx, tx, fileId, meta)
+				if err != nil {
+					return err
+				}
+			}
+
+			err = insertFileInfo(tx, fileId, links)
 			if err != nil {
-				return nil
+				return err
 			}
 		}
 
-		return nil
+		return recordHistory(ctx, tx, OpAddFiles, HistoryArgs{Files: filepaths, Labels: labelNames}, changes)
 	})
 
 	return err
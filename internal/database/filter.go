@@ -0,0 +1,466 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// FileFilter describes which files a query should return.
+// Empty fields are ignored.
+type FileFilter struct {
+	// Files must have every one of these labels attached
+	Labels []string `json:"labels,omitempty"`
+	// Label expression, see ParseQuery
+	Query string `json:"query,omitempty"`
+	// Don't match labels nested under the requested ones
+	Exact      bool   `json:"exact,omitempty"`
+	Pattern    string `json:"pattern,omitempty"`
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// Treat Pattern as a regular expression instead of a glob
+	Regex      bool `json:"regex,omitempty"`
+	IgnoreCase bool `json:"ignoreCase,omitempty"`
+	// Match Pattern against the last element of the path only
+	Basename bool `json:"basename,omitempty"`
+	// Full-text query over the indexed file contents
+	Contains string `json:"contains,omitempty"`
+	// Text the notes of the files must contain, ignoring case
+	Note string `json:"note,omitempty"`
+	// Only files which no longer exist
+	Missing bool `json:"missing,omitempty"`
+	// Only files which still exist
+	Existing bool `json:"existing,omitempty"`
+	// Only files added since or before these times, see parseTime
+	AddedSince  string `json:"addedSince,omitempty"`
+	AddedBefore string `json:"addedBefore,omitempty"`
+	// Size comparison, see parseSize
+	Size string `json:"size,omitempty"`
+	// Only files modified since the time, see parseTime
+	ModifiedSince string `json:"modifiedSince,omitempty"`
+	// One of the Type* constants
+	Type string `json:"type,omitempty"`
+	// Glob pattern the MIME type must match, e.g. image/*
+	Mime string `json:"mime,omitempty"`
+	// Only files without any labels, including the ones inherited from directories
+	Untagged bool `json:"untagged,omitempty"`
+	// Also return untracked files on disk beneath the matching directories
+	Walk bool `json:"walk,omitempty"`
+
+	// One of the Sort* constants, defaults to SortPath
+	Sort    string `json:"sort,omitempty"`
+	Reverse bool   `json:"reverse,omitempty"`
+	// Zero means no limit
+	Limit  int `json:"limit,omitempty"`
+	Offset int `json:"offset,omitempty"`
+}
+
+const (
+	SortPath   = "path"
+	SortName   = "name"
+	SortAdded  = "added"
+	SortLabels = "labels"
+)
+
+var (
+	ErrInvalidSort        = errors.New("invalid sort")
+	ErrMissingAndExisting = errors.New("files can't be both missing and existing")
+)
+
+// SQL expression for the last element of File.path
+var fileNameExpr = fmt.Sprintf(
+	"substr(File.path, length(rtrim(File.path, replace(File.path, '%[1]c', ''))) + 1)",
+	filepath.Separator,
+)
+
+var sortExprs = map[string]string{
+	SortPath:  "File.path",
+	SortName:  fileNameExpr,
+	SortAdded: "File.added_at",
+	SortLabels: `(SELECT COUNT(*) FROM FileInfo
+    JOIN Label ON Label.id = FileInfo.labelId
+    WHERE FileInfo.fileId = File.id)`,
+}
+
+// Columns ordering the files which sort equally, before their paths
+var sortTiebreaks = map[string]string{
+	// Ids are autoincremented, so they follow the order files were added in
+	SortAdded: "File.id",
+}
+
+type filterBuilder struct {
+	db    sqlx.Queryer
+	conds []string
+	args  []any
+	exact bool
+
+	// Saved searches currently being expanded, to catch self references
+	searches map[string]bool
+}
+
+func newFilterBuilder(db sqlx.Queryer) *filterBuilder {
+	return &filterBuilder{db: db, searches: map[string]bool{}}
+}
+
+func (b *filterBuilder) add(cond string, args ...any) {
+	b.conds = append(b.conds, cond)
+	b.args = append(b.args, args...)
+}
+
+func (b *filterBuilder) where() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(b.conds, " AND ")
+}
+
+func (b *filterBuilder) labelCondition(term labelTerm) (string, error) {
+	if IsSavedSearch(term.name) {
+		if len(term.op) > 0 {
+			return "", fmt.Errorf("%w: saved searches have no values", ErrInvalidQuery)
+		}
+
+		return b.savedSearchCondition(strings.TrimPrefix(term.name, SavedSearchPrefix))
+	}
+
+	name := canonicalLabelName(b.db, term.name)
+
+	cond := "Label.name = ?"
+	args := []any{name}
+
+	if !b.exact {
+		cond = "(" + cond + " OR Label.name GLOB ?)"
+		args = append(args, labelChildrenGlob(name))
+	}
+
+	if len(term.op) > 0 {
+		valueCond, value := valueCondition(term)
+		cond += " AND " + valueCond
+		args = append(args, value)
+	}
+
+	cond, args = ownedCondition(cond, args...)
+	b.args = append(b.args, args...)
+
+	return cond, nil
+}
+
+func (b *filterBuilder) savedSearchCondition(name string) (string, error) {
+	if b.searches[name] {
+		return "", fmt.Errorf("%w: %s%s", ErrSavedSearchRecursive, SavedSearchPrefix, name)
+	}
+
+	search, err := getSavedSearch(b.db, name)
+	if err != nil {
+		return "", err
+	}
+
+	b.searches[name] = true
+	defer delete(b.searches, name)
+
+	sub := &filterBuilder{db: b.db, searches: b.searches}
+	err = buildFileFilter(sub, search.Filter)
+	if err != nil {
+		return "", err
+	}
+
+	where := sub.where()
+	order, err := buildFileOrder(sub, search.Filter)
+	if err != nil {
+		return "", err
+	}
+
+	b.args = append(b.args, sub.args...)
+
+	return "File.id IN (SELECT File.id FROM File" + where + order + ")", nil
+}
+
+func buildFileFilter(b *filterBuilder, filter FileFilter) error {
+	b.exact = filter.Exact
+
+	for _, label := range filter.Labels {
+		cond, err := b.labelCondition(parseLabelTerm(label))
+		if err != nil {
+			return err
+		}
+
+		b.conds = append(b.conds, cond)
+	}
+
+	if len(filter.Query) > 0 {
+		query, err := ParseQuery(filter.Query)
+		if err != nil {
+			return err
+		}
+
+		cond, err := query.root.build(b)
+		if err != nil {
+			return err
+		}
+
+		b.conds = append(b.conds, cond)
+	}
+
+	if len(filter.PathPrefix) > 0 {
+		b.add("File.path GLOB ?", escapeGlob(filter.PathPrefix)+"*")
+	}
+
+	if len(filter.Pattern) > 0 {
+		err := buildPatternFilter(b, filter)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(filter.Contains) > 0 {
+		b.add("File.id IN (SELECT rowid FROM FileContent WHERE FileContent MATCH ?)", filter.Contains)
+	}
+
+	if len(filter.Note) > 0 {
+		b.add("File.id IN (SELECT fileId FROM Note WHERE text REGEXP ?)", "(?i)"+regexp.QuoteMeta(filter.Note))
+	}
+
+	if filter.Missing && filter.Existing {
+		return ErrMissingAndExisting
+	} else if filter.Missing {
+		b.add("NOT file_exists(File.path)")
+	} else if filter.Existing {
+		b.add("file_exists(File.path)")
+	}
+
+	now := time.Now()
+	if len(filter.AddedSince) > 0 {
+		since, err := parseTime(filter.AddedSince, now)
+		if err != nil {
+			return err
+		}
+		b.add("File.added_at >= ?", since.Unix())
+	}
+
+	if len(filter.AddedBefore) > 0 {
+		before, err := parseTime(filter.AddedBefore, now)
+		if err != nil {
+			return err
+		}
+		// Files without a recorded time may have been added at any time
+		b.add("File.added_at > 0 AND File.added_at < ?", before.Unix())
+	}
+
+	if len(filter.Size) > 0 {
+		op, size, err := parseSize(filter.Size)
+		if err != nil {
+			return err
+		}
+		b.add("File.modified_at > 0 AND File.size "+op+" ?", size)
+	}
+
+	if len(filter.ModifiedSince) > 0 {
+		since, err := parseTime(filter.ModifiedSince, now)
+		if err != nil {
+			return err
+		}
+		b.add("File.modified_at >= ?", since.Unix())
+	}
+
+	if len(filter.Type) > 0 {
+		err := typeCondition(b, filter.Type)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(filter.Mime) > 0 {
+		b.add("File.mime GLOB ?", strings.ToLower(filter.Mime))
+	}
+
+	if filter.Untagged {
+		cond, _ := ownedCondition("1")
+		b.add("NOT " + cond)
+	}
+
+	return nil
+}
+
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+func buildPatternFilter(b *filterBuilder, filter FileFilter) error {
+	target := "File.path"
+	if filter.Basename {
+		target = fileNameExpr
+	}
+
+	if !filter.Regex && !filter.IgnoreCase {
+		b.add(target+" GLOB ?", patternGlob(filter))
+		return nil
+	}
+
+	pattern := patternRegexp(filter)
+	_, err := compileRegexp(pattern)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPattern, err)
+	}
+
+	b.add(target+" REGEXP ?", pattern)
+
+	return nil
+}
+
+// patternGlob returns the GLOB pattern the filter's Pattern is matched with
+func patternGlob(filter FileFilter) string {
+	if filter.Basename {
+		return filter.Pattern
+	}
+
+	return "*" + filter.Pattern
+}
+
+// patternRegexp returns the regular expression equivalent to the filter's Pattern
+func patternRegexp(filter FileFilter) string {
+	pattern := filter.Pattern
+	if !filter.Regex {
+		pattern = globToRegexp(patternGlob(filter))
+	}
+	if filter.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	return pattern
+}
+
+// escapeGlob makes every character of s match literally in a GLOB pattern
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[':
+			sb.WriteRune('[')
+			sb.WriteRune(r)
+			sb.WriteRune(']')
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+// globToRegexp translates an sqlite GLOB pattern into an anchored regular expression
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '[':
+			end := i + 1
+			if end < len(runes) && runes[end] == '^' {
+				end++
+			}
+			// A ']' right after the opening bracket is a literal
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+
+			if end == len(runes) {
+				sb.WriteString(regexp.QuoteMeta(string(r)))
+				continue
+			}
+
+			class := strings.ReplaceAll(string(runes[i+1:end]), `\`, `\\`)
+			sb.WriteString("[" + class + "]")
+			i = end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	sb.WriteString("$")
+
+	return sb.String()
+}
+
+// buildFileOrder returns the ORDER BY and LIMIT clauses of the filter.
+// Must be called after the conditions have been built, to keep the arguments in order.
+func buildFileOrder(b *filterBuilder, filter FileFilter) (string, error) {
+	sort := filter.Sort
+	if len(sort) == 0 {
+		sort = SortPath
+	}
+
+	expr, ok := sortExprs[sort]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrInvalidSort, sort)
+	}
+
+	dir := " ASC"
+	if filter.Reverse {
+		dir = " DESC"
+	}
+
+	clause := " ORDER BY " + expr + dir
+	if tiebreak, ok := sortTiebreaks[sort]; ok {
+		clause += ", " + tiebreak + dir
+	}
+	if sort != SortPath {
+		clause += ", File.path" + dir
+	}
+
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return "", fmt.Errorf("%w: limit and offset can't be negative", ErrInvalidSort)
+	}
+
+	if filter.Limit > 0 || filter.Offset > 0 {
+		limit := filter.Limit
+		if limit == 0 {
+			limit = -1
+		}
+
+		clause += " LIMIT ? OFFSET ?"
+		b.args = append(b.args, limit, filter.Offset)
+	}
+
+	return clause, nil
+}
+
+func (m *DB) GetFilesWithFilter(filter FileFilter) ([]File, error) {
+	if filter.Walk {
+		return m.walkFilesWithFilter(filter)
+	}
+
+	b := newFilterBuilder(m.db)
+	err := buildFileFilter(b, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	where := b.where()
+	order, err := buildFileOrder(b, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `SELECT ` + fileColumns + ` FROM File` + where + order
+
+	files := []File{}
+	err = m.db.Select(&files, stmt, b.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	files = markDeletedFiles(files)
+
+	return files, nil
+}
@@ -0,0 +1,782 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/LeBulldoge/labee/internal/database/schema"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+func testNewDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	err = tx(context.TODO(), db, func(ctx context.Context, tx *sqlx.Tx) error {
+		return schema.ApplyMigrations(ctx, tx, 0, schema.TargetVersion)
+	})
+	if err != nil {
+		t.Fatalf("failed applying migrations: %v", err)
+	}
+
+	return &DB{db: db}
+}
+
+func testFilePaths(t *testing.T, db *DB, filter FileFilter) []string {
+	t.Helper()
+
+	files, err := db.GetFilesWithFilter(filter)
+	if err != nil {
+		t.Fatalf("failed getting files with %+v: %v", filter, err)
+	}
+
+	paths := []string{}
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+func TestFilterByQuery(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	links := map[string][]string{
+		"/a": {"work", "urgent"},
+		"/b": {"work", "review", "archived"},
+		"/c": {"work", "review"},
+		"/d": {"home"},
+	}
+	for path, labels := range links {
+		if err := db.AddFilesAndLinks(ctx, []string{path}, labels); err != nil {
+			t.Fatalf("failed adding %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		paths []string
+	}{
+		{query: "work", paths: []string{"/a", "/b", "/c"}},
+		{query: "work and (urgent or review) and not archived", paths: []string{"/a", "/c"}},
+		{query: "not work", paths: []string{"/d"}},
+		{query: "home or urgent", paths: []string{"/a", "/d"}},
+		{query: "NOT (work OR home)", paths: []string{}},
+	}
+
+	for _, test := range tests {
+		paths := testFilePaths(t, db, FileFilter{Query: test.query})
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("query %q: got %v, expected %v", test.query, paths, test.paths)
+		}
+	}
+
+	paths := testFilePaths(t, db, FileFilter{Labels: []string{"work"}, Query: "not review"})
+	if !reflect.DeepEqual(paths, []string{"/a"}) {
+		t.Errorf("labels combined with query: got %v", paths)
+	}
+}
+
+func TestFilterSavedSearch(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	links := map[string][]string{
+		"/docs/a": {"work"},
+		"/docs/b": {"home"},
+		"/c":      {"work"},
+	}
+	for path, labels := range links {
+		if err := db.AddFilesAndLinks(ctx, []string{path}, labels); err != nil {
+			t.Fatalf("failed adding %s: %v", path, err)
+		}
+	}
+
+	if err := db.SaveSearch(ctx, "docs", FileFilter{PathPrefix: "/docs/"}); err != nil {
+		t.Fatalf("failed saving a search: %v", err)
+	}
+	if err := db.SaveSearch(ctx, "work-docs", FileFilter{Labels: []string{"@docs", "work"}}); err != nil {
+		t.Fatalf("failed saving a search: %v", err)
+	}
+
+	tests := []struct {
+		filter   FileFilter
+		expected []string
+	}{
+		{FileFilter{Labels: []string{"@docs"}}, []string{"/docs/a", "/docs/b"}},
+		{FileFilter{Labels: []string{"@work-docs"}}, []string{"/docs/a"}},
+		{FileFilter{Query: "work and not @docs"}, []string{"/c"}},
+	}
+
+	for _, test := range tests {
+		paths := testFilePaths(t, db, test.filter)
+		if !reflect.DeepEqual(paths, test.expected) {
+			t.Errorf("filter %+v: got %v, expected %v", test.filter, paths, test.expected)
+		}
+	}
+
+	if _, err := db.GetFilesWithFilter(FileFilter{Labels: []string{"@missing"}}); !errors.Is(err, ErrSavedSearchNotFound) {
+		t.Errorf("expected ErrSavedSearchNotFound, got %v", err)
+	}
+
+	// Searches referring to each other can't be expanded
+	if err := db.SaveSearch(ctx, "a", FileFilter{Labels: []string{"@b"}}); err != nil {
+		t.Fatalf("failed saving a search: %v", err)
+	}
+	if err := db.SaveSearch(ctx, "b", FileFilter{Query: "work or @a"}); err != nil {
+		t.Fatalf("failed saving a search: %v", err)
+	}
+	if _, err := db.GetFilesWithFilter(FileFilter{Labels: []string{"@a"}}); !errors.Is(err, ErrSavedSearchRecursive) {
+		t.Errorf("expected ErrSavedSearchRecursive, got %v", err)
+	}
+}
+
+func TestFilterOrder(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	for _, path := range []string{"/b/a", "/a/c", "/c/b"} {
+		if err := db.AddFilesAndLinks(ctx, []string{path}, []string{"x"}); err != nil {
+			t.Fatalf("failed adding %s: %v", path, err)
+		}
+	}
+	if err := db.AddFilesAndLinks(ctx, []string{"/a/c"}, []string{"y"}); err != nil {
+		t.Fatalf("failed adding a label: %v", err)
+	}
+
+	tests := []struct {
+		filter FileFilter
+		paths  []string
+	}{
+		{filter: FileFilter{}, paths: []string{"/a/c", "/b/a", "/c/b"}},
+		{filter: FileFilter{Reverse: true}, paths: []string{"/c/b", "/b/a", "/a/c"}},
+		{filter: FileFilter{Sort: SortName}, paths: []string{"/b/a", "/c/b", "/a/c"}},
+		{filter: FileFilter{Sort: SortAdded}, paths: []string{"/b/a", "/a/c", "/c/b"}},
+		{filter: FileFilter{Sort: SortLabels, Reverse: true}, paths: []string{"/a/c", "/c/b", "/b/a"}},
+		{filter: FileFilter{Limit: 2}, paths: []string{"/a/c", "/b/a"}},
+		{filter: FileFilter{Offset: 2}, paths: []string{"/c/b"}},
+		{filter: FileFilter{Limit: 1, Offset: 1}, paths: []string{"/b/a"}},
+	}
+
+	for _, test := range tests {
+		files, err := db.GetFilesWithFilter(test.filter)
+		if err != nil {
+			t.Fatalf("failed getting files with %+v: %v", test.filter, err)
+		}
+
+		paths := []string{}
+		for _, f := range files {
+			paths = append(paths, f.Path)
+		}
+
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("filter %+v: got %v, expected %v", test.filter, paths, test.paths)
+		}
+	}
+}
+
+func TestFilterPattern(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	paths := []string{"/docs/Report-1.PDF", "/docs/report.pdf", "/report/notes.txt", "/a[1]/x.txt"}
+	if err := db.AddFilesAndLinks(ctx, paths, []string{"x"}); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+
+	tests := []struct {
+		filter FileFilter
+		paths  []string
+	}{
+		{filter: FileFilter{Pattern: "Report*.PDF"}, paths: []string{"/docs/Report-1.PDF"}},
+		{filter: FileFilter{Pattern: "Report*.PDF", IgnoreCase: true}, paths: []string{"/docs/Report-1.PDF", "/docs/report.pdf"}},
+		{filter: FileFilter{Pattern: "report*", Basename: true}, paths: []string{"/docs/report.pdf"}},
+		{filter: FileFilter{Pattern: "report*", Basename: true, IgnoreCase: true}, paths: []string{"/docs/Report-1.PDF", "/docs/report.pdf"}},
+		{filter: FileFilter{Pattern: `^/report/`, Regex: true}, paths: []string{"/report/notes.txt"}},
+		{filter: FileFilter{Pattern: `report-\d`, Regex: true, IgnoreCase: true}, paths: []string{"/docs/Report-1.PDF"}},
+		{filter: FileFilter{Pattern: `^[a-z]+\.txt$`, Regex: true, Basename: true}, paths: []string{"/a[1]/x.txt", "/report/notes.txt"}},
+		{filter: FileFilter{Pattern: "[nx]*.TXT", Basename: true, IgnoreCase: true}, paths: []string{"/a[1]/x.txt", "/report/notes.txt"}},
+		{filter: FileFilter{PathPrefix: "/a[1]"}, paths: []string{"/a[1]/x.txt"}},
+	}
+
+	for _, test := range tests {
+		paths := testFilePaths(t, db, test.filter)
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("filter %+v: got %v, expected %v", test.filter, paths, test.paths)
+		}
+	}
+
+	_, err := db.GetFilesWithFilter(FileFilter{Pattern: "(", Regex: true})
+	if !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("expected ErrInvalidPattern, got %v", err)
+	}
+}
+
+func TestFilterContains(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"report.txt": "Quarterly report for the board",
+		"notes.md":   "# Notes\nNothing quarterly here",
+		"image.png":  "quarterly",
+	}
+	paths := []string{}
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatalf("failed writing %s: %v", path, err)
+		}
+		paths = append(paths, path)
+	}
+	if err := db.AddFilesAndLinks(ctx, append(paths, "/missing.txt"), []string{"a"}); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+
+	testIndex := func(expected IndexStats) {
+		t.Helper()
+
+		stats, err := db.IndexFiles(ctx, false)
+		if err != nil {
+			t.Fatalf("failed indexing: %v", err)
+		}
+		if !reflect.DeepEqual(stats, expected) {
+			t.Errorf("index stats: got %+v, expected %+v", stats, expected)
+		}
+	}
+
+	report := filepath.Join(dir, "report.txt")
+	notes := filepath.Join(dir, "notes.md")
+
+	testIndex(IndexStats{Indexed: 2, Skipped: 2})
+	paths = testFilePaths(t, db, FileFilter{Contains: "quarterly"})
+	if !reflect.DeepEqual(paths, []string{notes, report}) {
+		t.Errorf("contents search: got %v", paths)
+	}
+	paths = testFilePaths(t, db, FileFilter{Contains: "board"})
+	if !reflect.DeepEqual(paths, []string{report}) {
+		t.Errorf("contents search: got %v", paths)
+	}
+
+	// Only changed files are indexed again, removed files are dropped from the index
+	later := time.Now().Add(time.Hour)
+	if err := os.WriteFile(report, []byte("Annual report"), 0o644); err != nil {
+		t.Fatalf("failed writing %s: %v", report, err)
+	}
+	if err := os.Chtimes(report, later, later); err != nil {
+		t.Fatalf("failed changing the time of %s: %v", report, err)
+	}
+	if err := os.Remove(notes); err != nil {
+		t.Fatalf("failed removing %s: %v", notes, err)
+	}
+
+	testIndex(IndexStats{Indexed: 1, Skipped: 3})
+	if paths := testFilePaths(t, db, FileFilter{Contains: "quarterly"}); len(paths) > 0 {
+		t.Errorf("contents search after changes: got %v", paths)
+	}
+	paths = testFilePaths(t, db, FileFilter{Contains: "annual"})
+	if !reflect.DeepEqual(paths, []string{report}) {
+		t.Errorf("contents search after changes: got %v", paths)
+	}
+
+	testIndex(IndexStats{Unchanged: 1, Skipped: 3})
+}
+
+func TestFilterState(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing")
+	if err := os.WriteFile(existing, nil, 0o644); err != nil {
+		t.Fatalf("failed creating a file: %v", err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	if err := db.AddFilesAndLinks(ctx, []string{existing, missing}, []string{"x"}); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+	untagged := filepath.Join(dir, "untagged")
+	if err := db.AddFilesAndLinks(ctx, []string{untagged}, nil); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+
+	tests := []struct {
+		filter FileFilter
+		paths  []string
+	}{
+		{filter: FileFilter{Missing: true}, paths: []string{missing, untagged}},
+		{filter: FileFilter{Existing: true}, paths: []string{existing}},
+		{filter: FileFilter{Untagged: true}, paths: []string{untagged}},
+		{filter: FileFilter{Missing: true, Labels: []string{"x"}}, paths: []string{missing}},
+	}
+
+	for _, test := range tests {
+		paths := testFilePaths(t, db, test.filter)
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("filter %+v: got %v, expected %v", test.filter, paths, test.paths)
+		}
+	}
+
+	_, err := db.GetFilesWithFilter(FileFilter{Missing: true, Existing: true})
+	if !errors.Is(err, ErrMissingAndExisting) {
+		t.Errorf("expected ErrMissingAndExisting, got %v", err)
+	}
+}
+
+func TestFilterNestedLabels(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	links := map[string][]string{
+		"/a": {"project"},
+		"/b": {"project/labee/docs"},
+		"/c": {"projects"},
+	}
+	for path, labels := range links {
+		if err := db.AddFilesAndLinks(ctx, []string{path}, labels); err != nil {
+			t.Fatalf("failed adding %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		filter FileFilter
+		paths  []string
+	}{
+		{filter: FileFilter{Labels: []string{"project"}}, paths: []string{"/a", "/b"}},
+		{filter: FileFilter{Labels: []string{"project"}, Exact: true}, paths: []string{"/a"}},
+		{filter: FileFilter{Query: "project/labee and not project/labee/src"}, paths: []string{"/b"}},
+	}
+
+	for _, test := range tests {
+		paths := testFilePaths(t, db, test.filter)
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("filter %+v: got %v, expected %v", test.filter, paths, test.paths)
+		}
+	}
+
+	if err := db.UpdateLabel(ctx, "project", "work", ""); err != nil {
+		t.Fatalf("failed renaming a label: %v", err)
+	}
+
+	for _, name := range []string{"work", "work/labee/docs", "projects"} {
+		if !db.LabelExists(name) {
+			t.Errorf("label %s doesn't exist after renaming", name)
+		}
+	}
+}
+
+func TestFilterValues(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	links := map[string][]string{
+		"/a": {"priority=1", "due=2000-01-01"},
+		"/b": {"priority=2.5", "due=tomorrow"},
+		"/c": {"priority=high"},
+		"/d": {"priority"},
+	}
+	for path, labels := range links {
+		if err := db.AddFilesAndLinks(ctx, []string{path}, labels); err != nil {
+			t.Fatalf("failed adding %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		filter FileFilter
+		paths  []string
+	}{
+		{filter: FileFilter{Labels: []string{"priority"}}, paths: []string{"/a", "/b", "/c", "/d"}},
+		{filter: FileFilter{Labels: []string{"priority>=2"}}, paths: []string{"/b"}},
+		{filter: FileFilter{Labels: []string{"priority<3"}}, paths: []string{"/a", "/b"}},
+		{filter: FileFilter{Labels: []string{"priority=high"}}, paths: []string{"/c"}},
+		{filter: FileFilter{Query: "due<today"}, paths: []string{"/a"}},
+		{filter: FileFilter{Query: "due>=today and not priority!=2.5"}, paths: []string{"/b"}},
+		{filter: FileFilter{Query: `"priority>=2"`}, paths: []string{}},
+	}
+
+	for _, test := range tests {
+		paths := testFilePaths(t, db, test.filter)
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("filter %+v: got %v, expected %v", test.filter, paths, test.paths)
+		}
+	}
+
+	if err := db.AddFilesAndLinks(ctx, []string{"/a"}, []string{"priority=3"}); err != nil {
+		t.Fatalf("failed updating a value: %v", err)
+	}
+
+	labels, err := db.GetFileLabels("/a")
+	if err != nil {
+		t.Fatalf("failed getting labels: %v", err)
+	}
+	for _, l := range labels {
+		if l.Name == "priority" && l.Value != "3" {
+			t.Errorf("value of priority is %q, expected 3", l.Value)
+		}
+	}
+}
+
+func TestFilterAliases(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	if err := db.AddFilesAndLinks(ctx, []string{"/a"}, []string{"todo"}); err != nil {
+		t.Fatalf("failed adding a file: %v", err)
+	}
+	if err := db.AddFilesAndLinks(ctx, []string{"/b"}, []string{"TODO"}); err != nil {
+		t.Fatalf("failed adding a file: %v", err)
+	}
+
+	// Labels are only merged when asked to
+	if _, err := db.AddLabelAliases(ctx, "TODO", []string{"todo", "to-do"}, false); !errors.Is(err, ErrAliasIsLabel) {
+		t.Errorf("expected ErrAliasIsLabel, got %v", err)
+	}
+	if paths := testFilePaths(t, db, FileFilter{Labels: []string{"todo"}}); !reflect.DeepEqual(paths, []string{"/a"}) {
+		t.Errorf("label todo after refusing to merge: got %v", paths)
+	}
+
+	merged, err := db.AddLabelAliases(ctx, "TODO", []string{"todo", "to-do"}, true)
+	if err != nil {
+		t.Fatalf("failed adding aliases: %v", err)
+	}
+	if !reflect.DeepEqual(merged, []string{"todo"}) {
+		t.Errorf("merged labels: got %v, expected [todo]", merged)
+	}
+
+	if err := db.AddFilesAndLinks(ctx, []string{"/c"}, []string{"to-do"}); err != nil {
+		t.Fatalf("failed adding a file: %v", err)
+	}
+
+	for _, name := range []string{"TODO", "todo", "to-do"} {
+		paths := testFilePaths(t, db, FileFilter{Labels: []string{name}})
+		if !reflect.DeepEqual(paths, []string{"/a", "/b", "/c"}) {
+			t.Errorf("label %s: got %v", name, paths)
+		}
+	}
+
+	labels, err := db.GetFileLabels("/c")
+	if err != nil {
+		t.Fatalf("failed getting labels: %v", err)
+	}
+	if len(labels) != 1 || labels[0].Name != "TODO" {
+		t.Errorf("labels of /c: got %+v, expected only TODO", labels)
+	}
+}
+
+func TestFilterNote(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	if err := db.SetNote(ctx, "/a", "Needs a *second* look"); err != nil {
+		t.Fatalf("failed setting a note: %v", err)
+	}
+	if err := db.SetNote(ctx, "/b", "done"); err != nil {
+		t.Fatalf("failed setting a note: %v", err)
+	}
+
+	paths := testFilePaths(t, db, FileFilter{Note: "SECOND*"})
+	if !reflect.DeepEqual(paths, []string{"/a"}) {
+		t.Errorf("note search: got %v, expected [/a]", paths)
+	}
+
+	if err := db.SetNote(ctx, "/a", ""); err != nil {
+		t.Fatalf("failed clearing a note: %v", err)
+	}
+
+	note, err := db.GetNote("/a")
+	if err != nil {
+		t.Fatalf("failed getting a note: %v", err)
+	}
+	if len(note) > 0 {
+		t.Errorf("cleared note: got %q", note)
+	}
+
+	paths = testFilePaths(t, db, FileFilter{Note: "second"})
+	if len(paths) > 0 {
+		t.Errorf("note search after clearing: got %v", paths)
+	}
+
+	// Clearing the note of a file which isn't stored doesn't store it
+	if err := db.SetNote(ctx, "/untracked", ""); err != nil {
+		t.Fatalf("failed clearing a note: %v", err)
+	}
+	paths = testFilePaths(t, db, FileFilter{})
+	if !reflect.DeepEqual(paths, []string{"/a", "/b"}) {
+		t.Errorf("files after clearing an untracked note: got %v", paths)
+	}
+}
+
+func TestFilterDirectoryLabels(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	archive := filepath.Join(project, "archive")
+	if err := os.MkdirAll(archive, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	tracked := filepath.Join(project, "tracked.txt")
+	untracked := filepath.Join(project, "untracked.txt")
+	archived := filepath.Join(archive, "old.txt")
+	for _, path := range []string{tracked, untracked, archived} {
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string][]string{
+		project: {"projectX"},
+		tracked: {"draft"},
+		archive: {"done"},
+	}
+	for path, labels := range links {
+		if err := db.AddFilesAndLinks(ctx, []string{path}, labels); err != nil {
+			t.Fatalf("failed adding %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		filter FileFilter
+		paths  []string
+	}{
+		{filter: FileFilter{Labels: []string{"projectX"}}, paths: []string{project, archive, tracked}},
+		{filter: FileFilter{Query: "projectX and not done"}, paths: []string{project, tracked}},
+		{filter: FileFilter{Labels: []string{"projectX"}, Walk: true}, paths: []string{project, archive, archived, tracked, untracked}},
+		{filter: FileFilter{Query: "projectX and not done", Walk: true}, paths: []string{project, tracked, untracked}},
+		{filter: FileFilter{Labels: []string{"projectX"}, Pattern: "*/untracked.*", Walk: true}, paths: []string{untracked}},
+		{filter: FileFilter{Labels: []string{"done"}, Walk: true}, paths: []string{archive, archived}},
+		{filter: FileFilter{Untagged: true}, paths: []string{}},
+	}
+
+	for _, test := range tests {
+		paths := testFilePaths(t, db, test.filter)
+		want := append([]string{}, test.paths...)
+		sort.Strings(want)
+		if !reflect.DeepEqual(paths, want) {
+			t.Errorf("filter %+v: got %v, expected %v", test.filter, paths, want)
+		}
+	}
+
+	inherited, err := db.GetInheritedLabels(archived)
+	if err != nil {
+		t.Fatalf("failed getting inherited labels: %v", err)
+	}
+
+	from := []string{}
+	for _, l := range inherited {
+		from = append(from, l.Name+" "+l.From)
+	}
+	if !reflect.DeepEqual(from, []string{"done " + archive, "projectX " + project}) {
+		t.Errorf("inherited labels of %s: got %v", archived, from)
+	}
+
+	if _, err := db.GetFilesWithFilter(FileFilter{Walk: true, Sort: SortAdded}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("walking sorted by added: expected ErrInvalidSort, got %v", err)
+	}
+}
+
+func TestFilterImplications(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	if err := db.AddFilesAndLinks(ctx, []string{"/old"}, []string{"invoice"}); err != nil {
+		t.Fatalf("failed adding a file: %v", err)
+	}
+
+	added, err := db.AddLabelImplications(ctx, "invoice", []string{"finance"})
+	if err != nil {
+		t.Fatalf("failed adding a rule: %v", err)
+	}
+	if added != 1 {
+		t.Errorf("retroactively added links: got %d, expected 1", added)
+	}
+
+	if _, err := db.AddLabelImplications(ctx, "finance", []string{"work"}); err != nil {
+		t.Fatalf("failed adding a rule: %v", err)
+	}
+	if _, err := db.AddLabelImplications(ctx, "work", []string{"invoice"}); !errors.Is(err, ErrImplicationCycle) {
+		t.Errorf("cyclic rule: expected ErrImplicationCycle, got %v", err)
+	}
+
+	if err := db.AddFilesAndLinks(ctx, []string{"/new"}, []string{"invoice"}); err != nil {
+		t.Fatalf("failed adding a file: %v", err)
+	}
+
+	paths := testFilePaths(t, db, FileFilter{Query: "finance and work"})
+	if !reflect.DeepEqual(paths, []string{"/new", "/old"}) {
+		t.Errorf("implied labels: got %v, expected [/new /old]", paths)
+	}
+
+	if err := db.DeleteLabelImplications(ctx, "invoice", []string{"finance"}); err != nil {
+		t.Fatalf("failed deleting a rule: %v", err)
+	}
+
+	rules, err := db.GetLabelImplications()
+	if err != nil {
+		t.Fatalf("failed getting rules: %v", err)
+	}
+	if !reflect.DeepEqual(rules, []LabelImplication{{Label: "finance", Implied: "work"}}) {
+		t.Errorf("rules: got %+v", rules)
+	}
+}
+
+func TestFilterAdded(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	if err := db.AddFilesAndLinks(ctx, []string{"/new", "/old", "/unknown"}, []string{"a"}); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+
+	file, err := db.GetFile("/new")
+	if err != nil {
+		t.Fatalf("failed getting a file: %v", err)
+	}
+	if time.Since(time.Unix(file.AddedAt, 0)) > time.Minute {
+		t.Errorf("added time of a new file: got %d", file.AddedAt)
+	}
+
+	old := time.Now().AddDate(0, 0, -30).Unix()
+	if _, err := db.db.Exec(`UPDATE File SET added_at = ? WHERE path = '/old'`, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.db.Exec(`UPDATE File SET added_at = 0 WHERE path = '/unknown'`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filter FileFilter
+		paths  []string
+	}{
+		{filter: FileFilter{AddedSince: "7d"}, paths: []string{"/new"}},
+		{filter: FileFilter{AddedSince: "today"}, paths: []string{"/new"}},
+		{filter: FileFilter{AddedBefore: "2w"}, paths: []string{"/old"}},
+		{filter: FileFilter{AddedSince: "5w", AddedBefore: "yesterday"}, paths: []string{"/old"}},
+	}
+
+	for _, test := range tests {
+		paths := testFilePaths(t, db, test.filter)
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("filter %+v: got %v, expected %v", test.filter, paths, test.paths)
+		}
+	}
+
+	// Files without a recorded time sort first
+	files, err := db.GetFilesWithFilter(FileFilter{Sort: SortAdded})
+	if err != nil {
+		t.Fatalf("failed sorting by the added time: %v", err)
+	}
+	paths := []string{}
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"/unknown", "/old", "/new"}) {
+		t.Errorf("sorted by the added time: got %v", paths)
+	}
+
+	for _, s := range []string{"7", "d", "-2d", "last week", "2026-13-01"} {
+		if _, err := db.GetFilesWithFilter(FileFilter{AddedSince: s}); !errors.Is(err, ErrInvalidTime) {
+			t.Errorf("time %q: expected ErrInvalidTime, got %v", s, err)
+		}
+	}
+}
+
+func TestFilterMeta(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "dir")
+	small := filepath.Join(root, "small.txt")
+	large := filepath.Join(root, "large.png")
+	link := filepath.Join(root, "link")
+
+	if err := os.Mkdir(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(small, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(large, make([]byte, 2<<10), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(small, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AddFilesAndLinks(ctx, []string{dir, small, large, link, "/gone"}, []string{"a"}); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+
+	tests := []struct {
+		filter FileFilter
+		paths  []string
+	}{
+		{filter: FileFilter{Size: "+1k", Type: TypeFile}, paths: []string{large}},
+		{filter: FileFilter{Size: "-1k", Type: TypeFile}, paths: []string{small}},
+		{filter: FileFilter{Size: "5"}, paths: []string{small}},
+		{filter: FileFilter{Type: TypeDir}, paths: []string{dir}},
+		{filter: FileFilter{Type: TypeSymlink}, paths: []string{link}},
+		{filter: FileFilter{Mime: "image/*"}, paths: []string{large}},
+		{filter: FileFilter{Mime: "text/plain"}, paths: []string{small}},
+		{filter: FileFilter{ModifiedSince: "1h", Type: TypeFile}, paths: []string{large, small}},
+	}
+
+	for _, test := range tests {
+		paths := testFilePaths(t, db, test.filter)
+		want := append([]string{}, test.paths...)
+		sort.Strings(want)
+		if !reflect.DeepEqual(paths, want) {
+			t.Errorf("filter %+v: got %v, expected %v", test.filter, paths, want)
+		}
+	}
+
+	if err := os.WriteFile(small, make([]byte, 4<<10), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := db.GetFilesWithFilter(FileFilter{})
+	if err != nil {
+		t.Fatalf("failed getting files: %v", err)
+	}
+
+	refreshed, missing, err := db.RefreshFiles(ctx, files)
+	if err != nil {
+		t.Fatalf("failed refreshing files: %v", err)
+	}
+	if refreshed != 4 || missing != 1 {
+		t.Errorf("refresh: got %d refreshed and %d missing, expected 4 and 1", refreshed, missing)
+	}
+
+	paths := testFilePaths(t, db, FileFilter{Size: "4k", Type: TypeFile})
+	if !reflect.DeepEqual(paths, []string{small}) {
+		t.Errorf("size after refresh: got %v, expected [%s]", paths, small)
+	}
+
+	for _, filter := range []FileFilter{{Size: "+"}, {Size: "10x"}, {Type: "pipe"}} {
+		if _, err := db.GetFilesWithFilter(filter); !errors.Is(err, ErrInvalidSize) && !errors.Is(err, ErrInvalidType) {
+			t.Errorf("filter %+v: expected an error, got %v", filter, err)
+		}
+	}
+}
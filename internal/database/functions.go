@@ -0,0 +1,75 @@
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/LeBulldoge/labee/internal/os"
+	"modernc.org/sqlite"
+)
+
+// SQL functions missing from sqlite, available on every connection
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("regexp", 2, regexpFunc)
+	sqlite.MustRegisterScalarFunction("file_exists", 1, fileExistsFunc)
+}
+
+var regexpCache sync.Map
+
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexpCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache.Store(pattern, re)
+
+	return re, nil
+}
+
+func textArg(v driver.Value) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+
+	return "", false
+}
+
+// regexp(pattern, text) backs the `text REGEXP pattern` operator
+func regexpFunc(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	pattern, ok := textArg(args[0])
+	if !ok {
+		return nil, fmt.Errorf("regexp: pattern must be text, got %T", args[0])
+	}
+
+	text, ok := textArg(args[1])
+	if !ok {
+		// NULL or non text values never match
+		return false, nil
+	}
+
+	re, err := compileRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return re.MatchString(text), nil
+}
+
+// file_exists(path) checks the filesystem, so it's not deterministic
+func fileExistsFunc(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	path, ok := textArg(args[0])
+	if !ok {
+		return false, nil
+	}
+
+	return os.FileExists(path), nil
+}
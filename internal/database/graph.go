@@ -0,0 +1,81 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+type RelatedLabel struct {
+	Label
+	// Amount of files both labels are attached to
+	Shared int `db:"shared"`
+}
+
+type LabelEdge struct {
+	From   string `db:"source" json:"from"`
+	To     string `db:"target" json:"to"`
+	Weight int    `db:"weight" json:"weight"`
+}
+
+var ErrRelatedSavedSearch = errors.New("saved searches have no related labels")
+
+// GetRelatedLabels returns the labels attached to the same files as the label name,
+// the most common first, along with the amount of files the label itself is attached to.
+// Only the files the label is attached to directly are counted, so that the shared
+// amounts never exceed it.
+func (m *DB) GetRelatedLabels(name string) ([]RelatedLabel, int, error) {
+	if IsSavedSearch(name) {
+		return nil, 0, fmt.Errorf("%w: %s", ErrRelatedSavedSearch, name)
+	}
+	name = canonicalLabelName(m.db, name)
+
+	var files int
+	err := m.db.Get(&files,
+		`SELECT COUNT(*) FROM FileInfo
+    JOIN File ON File.id = FileInfo.fileId
+    JOIN Label ON Label.id = FileInfo.labelId
+    WHERE Label.name = $1`,
+		name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT Label.id, Label.name, Label.color, COUNT(*) AS shared
+    FROM FileInfo AS a
+    JOIN FileInfo AS b ON b.fileId = a.fileId AND b.labelId != a.labelId
+    JOIN File ON File.id = a.fileId
+    JOIN Label AS source ON source.id = a.labelId
+    JOIN Label ON Label.id = b.labelId
+    WHERE source.name = $1
+    GROUP BY Label.id
+    ORDER BY shared DESC, Label.name`
+
+	labels := []RelatedLabel{}
+	err = m.db.Select(&labels, stmt, name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return labels, files, nil
+}
+
+// GetLabelEdges returns every pair of labels attached to the same files,
+// weighted by the amount of such files
+func (m *DB) GetLabelEdges() ([]LabelEdge, error) {
+	stmt := `SELECT source.name AS source, target.name AS target, COUNT(*) AS weight
+    FROM FileInfo AS a
+    JOIN FileInfo AS b ON b.fileId = a.fileId AND b.labelId > a.labelId
+    JOIN File ON File.id = a.fileId
+    JOIN Label AS source ON source.id = a.labelId
+    JOIN Label AS target ON target.id = b.labelId
+    GROUP BY a.labelId, b.labelId
+    ORDER BY weight DESC, source.name, target.name`
+
+	edges := []LabelEdge{}
+	err := m.db.Select(&edges, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	return edges, nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRelatedLabels(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	// Labels are created in this order, which decides the direction of the edges
+	links := []struct {
+		path   string
+		labels []string
+	}{
+		{"/a", []string{"x", "y"}},
+		{"/b", []string{"x", "y", "z"}},
+		{"/c", []string{"x"}},
+		{"/d", []string{"x/sub"}},
+		{"/gone", []string{"x", "z"}},
+	}
+	for _, l := range links {
+		if err := db.AddFilesAndLinks(ctx, []string{l.path}, l.labels); err != nil {
+			t.Fatalf("failed adding %s: %v", l.path, err)
+		}
+	}
+	if err := db.DeleteFiles(ctx, []string{"/gone"}); err != nil {
+		t.Fatalf("failed deleting a file: %v", err)
+	}
+	if _, err := db.AddLabelAliases(ctx, "x", []string{"ex"}, false); err != nil {
+		t.Fatalf("failed adding an alias: %v", err)
+	}
+
+	// Nested labels and removed files aren't counted
+	related, files, err := db.GetRelatedLabels("ex")
+	if err != nil {
+		t.Fatalf("failed getting related labels: %v", err)
+	}
+	if files != 3 {
+		t.Errorf("files: got %d, expected 3", files)
+	}
+
+	got := map[string]int{}
+	names := []string{}
+	for _, r := range related {
+		got[r.Name] = r.Shared
+		names = append(names, r.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"y", "z"}) || got["y"] != 2 || got["z"] != 1 {
+		t.Errorf("related labels: got %v", got)
+	}
+
+	if _, _, err := db.GetRelatedLabels("@search"); !errors.Is(err, ErrRelatedSavedSearch) {
+		t.Errorf("expected ErrRelatedSavedSearch, got %v", err)
+	}
+
+	edges, err := db.GetLabelEdges()
+	if err != nil {
+		t.Fatalf("failed getting label edges: %v", err)
+	}
+
+	expected := []LabelEdge{
+		{From: "x", To: "y", Weight: 2},
+		{From: "x", To: "z", Weight: 1},
+		{From: "y", To: "z", Weight: 1},
+	}
+	if !reflect.DeepEqual(edges, expected) {
+		t.Errorf("edges: got %+v, expected %+v", edges, expected)
+	}
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/LeBulldoge/labee/internal/os"
+	"github.com/jmoiron/sqlx"
+)
+
+type HashedFile struct {
+	File
+	// Hex encoded SHA-256 of the contents, empty if never hashed
+	Hash string `db:"hash"`
+	// Modification time of the file when it was hashed, in nanoseconds
+	HashedMtime int64 `db:"hashed_mtime"`
+}
+
+type HashEntry struct {
+	FileId int64
+	Hash   string
+	Mtime  int64
+}
+
+var ErrNotDuplicate = errors.New("files don't share their contents")
+
+// GetHashedFiles returns the stored files with the given ids, or every stored file if none are given
+func (m *DB) GetHashedFiles(ids ...int64) ([]HashedFile, error) {
+	stmt := `SELECT ` + fileColumns + `, File.hash, File.hashed_mtime FROM File`
+	args := []any{}
+	if len(ids) > 0 {
+		var err error
+		stmt, args, err = sqlx.In(stmt+` WHERE File.id IN (?)`, ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	files := []HashedFile{}
+	err := m.db.Select(&files, stmt+` ORDER BY File.path`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (m *DB) UpdateHashes(ctx context.Context, entries []HashEntry) error {
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		for _, e := range entries {
+			_, err := tx.ExecContext(ctx,
+				`UPDATE File SET hash = $1, hashed_mtime = $2 WHERE id = $3`,
+				e.Hash, e.Mtime, e.FileId)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// GetDuplicateFiles returns groups of stored files sharing the same hash, ordered by path
+func (m *DB) GetDuplicateFiles() ([][]HashedFile, error) {
+	stmt := `SELECT ` + fileColumns + `, File.hash, File.hashed_mtime FROM File
+    WHERE File.hash IN (
+      SELECT hash FROM File WHERE hash != '' GROUP BY hash HAVING COUNT(*) > 1
+    )
+    ORDER BY File.hash, File.path`
+
+	files := []HashedFile{}
+	err := m.db.Select(&files, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := [][]HashedFile{}
+	for i, f := range files {
+		if i == 0 || files[i-1].Hash != f.Hash {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], f)
+	}
+
+	for _, g := range groups {
+		for i := range g {
+			g[i].Deleted = !os.FileExists(g[i].Path)
+		}
+	}
+
+	return groups, nil
+}
+
+// MergeDuplicateLabels attaches the labels of the copies to the kept file as well.
+// The copies keep their labels. Values already present on the kept file's links are kept.
+func (m *DB) MergeDuplicateLabels(ctx context.Context, keepId int64, copyIds []int64) error {
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		ids := append([]int64{keepId}, copyIds...)
+		changes, err := trackChanges(ctx, tx, fileLinkScope(ids))
+		if err != nil {
+			return err
+		}
+
+		for _, id := range copyIds {
+			var cnt int
+			err := tx.GetContext(ctx, &cnt,
+				`SELECT COUNT(*) FROM File AS Kept, File AS Copy
+        WHERE Kept.id = $1 AND Copy.id = $2 AND Kept.hash != '' AND Kept.hash = Copy.hash`,
+				keepId, id)
+			if err != nil {
+				return err
+			}
+			if cnt == 0 {
+				return fmt.Errorf("%w: %d and %d", ErrNotDuplicate, keepId, id)
+			}
+
+			_, err = tx.ExecContext(ctx,
+				`INSERT OR IGNORE INTO FileInfo (fileId, labelId, value, linked_at)
+        SELECT $1, labelId, value, linked_at FROM FileInfo WHERE fileId = $2`,
+				keepId, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		// The kept file comes first, as its id does
+		paths := []string{}
+		err = tx.SelectContext(ctx, &paths,
+			`SELECT File.path FROM json_each(?) JOIN File ON File.id = json_each.value ORDER BY json_each.key`,
+			jsonList(ids))
+		if err != nil {
+			return err
+		}
+
+		return recordHistory(ctx, tx, OpMergeDuplicates, HistoryArgs{Files: paths}, changes)
+	})
+
+	return err
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestDuplicateFiles(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	links := map[string][]string{
+		"/a": {"work"},
+		"/b": {"draft", "priority=2"},
+		"/c": {},
+	}
+	for path, labels := range links {
+		if err := db.AddFilesAndLinks(ctx, []string{path}, labels); err != nil {
+			t.Fatalf("failed adding %s: %v", path, err)
+		}
+	}
+
+	files, err := db.GetHashedFiles()
+	if err != nil {
+		t.Fatalf("failed getting files: %v", err)
+	}
+
+	hashes := map[string]string{"/a": "same", "/b": "same", "/c": "other"}
+	entries := []HashEntry{}
+	for _, f := range files {
+		entries = append(entries, HashEntry{FileId: f.Id, Hash: hashes[f.Path], Mtime: 1})
+	}
+	if err := db.UpdateHashes(ctx, entries); err != nil {
+		t.Fatalf("failed updating hashes: %v", err)
+	}
+
+	groups, err := db.GetDuplicateFiles()
+	if err != nil {
+		t.Fatalf("failed getting duplicates: %v", err)
+	}
+	if len(groups) != 1 || len(groups[0]) != 2 || groups[0][0].Path != "/a" || groups[0][1].Path != "/b" {
+		t.Fatalf("duplicates: got %+v, expected /a and /b", groups)
+	}
+
+	a, b := groups[0][0], groups[0][1]
+	if err := db.MergeDuplicateLabels(ctx, a.Id, []int64{b.Id}); err != nil {
+		t.Fatalf("failed merging labels: %v", err)
+	}
+
+	labels, err := db.GetFileLabels("/a")
+	if err != nil {
+		t.Fatalf("failed getting labels: %v", err)
+	}
+	names := []string{}
+	for _, l := range labels {
+		names = append(names, l.Name+"="+l.Value)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"draft=", "priority=2", "work="}) {
+		t.Errorf("merged labels: got %v", names)
+	}
+
+	labels, err = db.GetFileLabels("/b")
+	if err != nil {
+		t.Fatalf("failed getting labels: %v", err)
+	}
+	names = []string{}
+	for _, l := range labels {
+		names = append(names, l.Name+"="+l.Value)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"draft=", "priority=2"}) {
+		t.Errorf("labels of the copy: got %v", names)
+	}
+
+	c, err := db.GetFile("/c")
+	if err != nil {
+		t.Fatalf("failed getting a file: %v", err)
+	}
+	if err := db.MergeDuplicateLabels(ctx, a.Id, []int64{c.Id}); !errors.Is(err, ErrNotDuplicate) {
+		t.Errorf("merging a different file: expected ErrNotDuplicate, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"github.com/LeBulldoge/labee/internal/os"
+	"github.com/jmoiron/sqlx"
+)
+
+// Operations recorded in the history
+const (
+	OpAddFiles           = "add"
+	OpDeleteFiles        = "remove-files"
+	OpDeleteLabel        = "remove-label"
+	OpUpdateLabel        = "edit-label"
+	OpAddAliases         = "alias"
+	OpDeleteAliases      = "unalias"
+	OpAddImplications    = "imply"
+	OpDeleteImplications = "unimply"
+	OpMergeDuplicates    = "merge-dupes"
+	OpMoveFiles          = "move"
+	OpRelinkFiles        = "relink"
+	OpSetNote            = "note"
+	OpSaveSearch         = "save-search"
+	OpDeleteSearch       = "remove-search"
+)
+
+// Arguments of an operation, as they were given
+type HistoryArgs struct {
+	// Files moved or relinked are recorded in pairs of their old and new paths.
+	// The file labels are merged onto comes before its copies.
+	Files  []string `json:"files,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+	// New name and color of an edited label
+	Name  string `json:"name,omitempty"`
+	Color string `json:"color,omitempty"`
+	// Aliases or implied labels given to the label
+	Aliases []string `json:"aliases,omitempty"`
+	Implied []string `json:"implied,omitempty"`
+	// Name of a saved search
+	Search string `json:"search,omitempty"`
+}
+
+type HistoryEntry struct {
+	Id   int64
+	Op   string
+	Args HistoryArgs
+	// Unix time
+	At int64
+	// OS user who made the change
+	User string
+	// Unix time the change was undone at, zero if it wasn't
+	UndoneAt int64
+}
+
+type HistoryFilter struct {
+	// Only changes to the file or to anything beneath it, including
+	// the changes to the labels attached to them
+	File string
+	// Only changes to the label, including its links to files
+	Label string
+	// Only changes made since or before these times, see parseTime
+	Since  string
+	Before string
+	Limit  int
+}
+
+type historyRow struct {
+	Id   int64  `db:"id"`
+	Op   string `db:"op"`
+	Args string `db:"args"`
+	At   int64  `db:"at"`
+	User string `db:"user"`
+	// JSON list of undoStmt
+	Undo     string `db:"undo"`
+	UndoneAt int64  `db:"undone_at"`
+}
+
+func (r historyRow) toHistoryEntry() (HistoryEntry, error) {
+	entry := HistoryEntry{Id: r.Id, Op: r.Op, At: r.At, User: r.User, UndoneAt: r.UndoneAt}
+	err := json.Unmarshal([]byte(r.Args), &entry.Args)
+
+	return entry, err
+}
+
+// labelFilePaths returns the paths of the stored files the label is attached to,
+// along with the files of the labels nested under it if children is set
+func labelFilePaths(db sqlx.Queryer, name string, children bool) ([]string, error) {
+	paths := []string{}
+	err := sqlx.Select(db, &paths,
+		`SELECT DISTINCT File.path FROM FileInfo
+    JOIN File ON File.id = FileInfo.fileId
+    JOIN Label ON Label.id = FileInfo.labelId
+    WHERE Label.name = $1 OR ($2 AND Label.name GLOB $3)
+    ORDER BY File.path`,
+		name, children, labelChildrenGlob(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// labelNames returns the name along with every name of the label it refers to,
+// as changes may be recorded under any of them
+func labelNames(db sqlx.Queryer, name string) ([]string, error) {
+	canonical := canonicalLabelName(db, name)
+
+	names := []string{}
+	err := sqlx.Select(db, &names,
+		`SELECT name FROM LabelAlias WHERE labelId = (SELECT id FROM Label WHERE name = ?) AND name != ?`,
+		canonical, name)
+	if err != nil {
+		return nil, err
+	}
+
+	names = append(names, name)
+	if canonical != name {
+		names = append(names, canonical)
+	}
+
+	return names, nil
+}
+
+// recordHistory appends the operation to the history as part of the transaction making it,
+// along with the statements reverting the changes found by the tracker
+func recordHistory(ctx context.Context, tx *sqlx.Tx, op string, args HistoryArgs, changes *changeTracker) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	stmts, err := changes.undo(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	undo, err := json.Marshal(stmts)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO History (op, args, user, undo) VALUES ($1, $2, $3, $4)`,
+		op, string(data), os.Username(), string(undo))
+
+	return err
+}
+
+// GetHistory returns the recorded operations matching the filter, latest first
+func (m *DB) GetHistory(filter HistoryFilter) ([]HistoryEntry, error) {
+	b := newFilterBuilder(m.db)
+	now := time.Now()
+
+	if len(filter.File) > 0 {
+		b.add(`EXISTS (SELECT 1 FROM json_each(History.args, '$.files')
+      WHERE value = ? OR substr(value, 1, length(?) + 1) = ?)`,
+			filter.File, filter.File, filter.File+string(filepath.Separator))
+	}
+
+	if len(filter.Label) > 0 {
+		names, err := labelNames(m.db, filter.Label)
+		if err != nil {
+			return nil, err
+		}
+
+		// Links are recorded with their values, e.g. priority=2
+		b.add(`(json_extract(History.args, '$.name') IN (SELECT value FROM json_each(?)) OR EXISTS (SELECT 1 FROM (
+        SELECT value FROM json_each(History.args, '$.labels')
+        UNION ALL SELECT value FROM json_each(History.args, '$.aliases')
+        UNION ALL SELECT value FROM json_each(History.args, '$.implied')) AS Arg, json_each(?) AS Name
+      WHERE Arg.value = Name.value OR substr(Arg.value, 1, length(Name.value) + 1) = Name.value || '='))`,
+			jsonList(names), jsonList(names))
+	}
+
+	if len(filter.Since) > 0 {
+		since, err := parseTime(filter.Since, now)
+		if err != nil {
+			return nil, err
+		}
+		b.add("History.at >= ?", since.Unix())
+	}
+
+	if len(filter.Before) > 0 {
+		before, err := parseTime(filter.Before, now)
+		if err != nil {
+			return nil, err
+		}
+		b.add("History.at < ?", before.Unix())
+	}
+
+	stmt := "SELECT * FROM History" + b.where() + " ORDER BY History.id DESC"
+	if filter.Limit > 0 {
+		stmt += " LIMIT ?"
+		b.args = append(b.args, filter.Limit)
+	}
+
+	rows := []historyRow{}
+	err := m.db.Select(&rows, stmt, b.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []HistoryEntry{}
+	for _, r := range rows {
+		entry, err := r.toHistoryEntry()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
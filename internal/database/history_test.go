@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func testHistoryOps(t *testing.T, db *DB, filter HistoryFilter) []string {
+	t.Helper()
+
+	entries, err := db.GetHistory(filter)
+	if err != nil {
+		t.Fatalf("failed getting history with %+v: %v", filter, err)
+	}
+
+	ops := []string{}
+	for _, e := range entries {
+		ops = append(ops, e.Op)
+	}
+
+	return ops
+}
+
+func TestHistory(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	if err := db.AddFilesAndLinks(ctx, []string{"/dir/a", "/b"}, []string{"x", "priority=2"}); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+	if err := db.UpdateLabel(ctx, "x", "y", "#ffffff"); err != nil {
+		t.Fatalf("failed updating a label: %v", err)
+	}
+	if err := db.DeleteFiles(ctx, []string{"/b"}); err != nil {
+		t.Fatalf("failed deleting a file: %v", err)
+	}
+	if err := db.DeleteLabel(ctx, "y"); err != nil {
+		t.Fatalf("failed deleting a label: %v", err)
+	}
+
+	// Failed changes aren't recorded
+	if err := db.DeleteFiles(ctx, []string{"/missing"}); err == nil {
+		t.Fatal("expected an error deleting a file which isn't stored")
+	}
+	if err := db.DeleteLabel(ctx, "missing"); err == nil {
+		t.Fatal("expected an error deleting a label which doesn't exist")
+	}
+
+	entries, err := db.GetHistory(HistoryFilter{})
+	if err != nil {
+		t.Fatalf("failed getting history: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	expected := HistoryArgs{Files: []string{"/b", "/dir/a"}, Labels: []string{"x"}, Name: "y", Color: "#ffffff"}
+	if !reflect.DeepEqual(entries[2].Args, expected) {
+		t.Errorf("args: got %+v, expected %+v", entries[2].Args, expected)
+	}
+	if entries[0].At == 0 {
+		t.Error("expected the time of the change to be recorded")
+	}
+
+	tests := []struct {
+		filter   HistoryFilter
+		expected []string
+	}{
+		{HistoryFilter{}, []string{OpDeleteLabel, OpDeleteFiles, OpUpdateLabel, OpAddFiles}},
+		{HistoryFilter{Limit: 2}, []string{OpDeleteLabel, OpDeleteFiles}},
+		// Labels removed from the files or changed count as changes to the files
+		{HistoryFilter{File: "/b"}, []string{OpDeleteFiles, OpUpdateLabel, OpAddFiles}},
+		{HistoryFilter{File: "/dir"}, []string{OpDeleteLabel, OpUpdateLabel, OpAddFiles}},
+		{HistoryFilter{File: "/di"}, []string{}},
+		{HistoryFilter{Label: "y"}, []string{OpDeleteLabel, OpUpdateLabel}},
+		{HistoryFilter{Label: "priority"}, []string{OpAddFiles}},
+		{HistoryFilter{Since: "1h"}, []string{OpDeleteLabel, OpDeleteFiles, OpUpdateLabel, OpAddFiles}},
+		{HistoryFilter{Before: "1h"}, []string{}},
+	}
+
+	for _, test := range tests {
+		got := testHistoryOps(t, db, test.filter)
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("filter %+v: got %v, expected %v", test.filter, got, test.expected)
+		}
+	}
+
+	// Changes are found by any name of the label
+	if err := db.AddFilesAndLinks(ctx, []string{"/c"}, []string{"work"}); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+	if _, err := db.AddLabelAliases(ctx, "work", []string{"job"}, false); err != nil {
+		t.Fatalf("failed adding an alias: %v", err)
+	}
+	for _, name := range []string{"job", "work"} {
+		got := testHistoryOps(t, db, HistoryFilter{Label: name})
+		if expected := []string{OpAddAliases, OpAddFiles}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("label %s: got %v, expected %v", name, got, expected)
+		}
+	}
+}
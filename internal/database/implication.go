@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// A rule which attaches the implied label whenever the label is attached
+type LabelImplication struct {
+	Label   string `db:"label"`
+	Implied string `db:"implied"`
+}
+
+var (
+	ErrImplicationCycle    = errors.New("implication rules can't form a cycle")
+	ErrImplicationNotFound = errors.New("implication rule does not exist")
+)
+
+// getImplications returns the implied label ids of every label id with rules
+func getImplications(db sqlx.Queryer) (map[int64][]int64, error) {
+	rows := []struct {
+		LabelId   int64 `db:"labelId"`
+		ImpliedId int64 `db:"impliedId"`
+	}{}
+	err := sqlx.Select(db, &rows, `SELECT labelId, impliedId FROM LabelImplication`)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := map[int64][]int64{}
+	for _, r := range rows {
+		rules[r.LabelId] = append(rules[r.LabelId], r.ImpliedId)
+	}
+
+	return rules, nil
+}
+
+// impliedLabelIds follows the rules from the labels, returning every label they
+// imply directly or through other labels, in the order they were reached
+func impliedLabelIds(rules map[int64][]int64, ids []int64) []int64 {
+	seen := map[int64]bool{}
+	for _, id := range ids {
+		seen[id] = true
+	}
+
+	var implied []int64
+	queue := append([]int64{}, ids...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, next := range rules[id] {
+			if seen[next] {
+				continue
+			}
+			seen[next] = true
+			implied = append(implied, next)
+			queue = append(queue, next)
+		}
+	}
+
+	return implied
+}
+
+// withImpliedLinks appends links without values for the labels implied by the links
+func withImpliedLinks(tx *sqlx.Tx, links []labelLink) ([]labelLink, error) {
+	rules, err := getImplications(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []int64{}
+	for _, link := range links {
+		ids = append(ids, link.id)
+	}
+
+	for _, id := range impliedLabelIds(rules, ids) {
+		links = append(links, labelLink{id: id})
+	}
+
+	return links, nil
+}
+
+// AddLabelImplications makes the label imply the other labels, creating them if needed.
+// The implied labels are attached to every file the label is already attached to.
+// Returns the amount of links added to the files.
+func (m *DB) AddLabelImplications(ctx context.Context, name string, implied []string) (int64, error) {
+	var added int64
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		// Implied labels are attached to the files with the label
+		links, err := labelFilesScope(ctx, tx, []string{name})
+		if err != nil {
+			return err
+		}
+
+		changes, err := trackChanges(ctx, tx, labelScope, labelImplicationScope, links)
+		if err != nil {
+			return err
+		}
+
+		label, err := getOrInsertLabel(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+
+		for _, impliedName := range implied {
+			impliedLabel, err := getOrInsertLabel(ctx, tx, impliedName)
+			if err != nil {
+				return err
+			}
+
+			rules, err := getImplications(tx)
+			if err != nil {
+				return err
+			}
+
+			if impliedLabel.Id == label.Id {
+				return fmt.Errorf("%w: %s implies itself", ErrImplicationCycle, label.Name)
+			}
+			for _, id := range impliedLabelIds(rules, []int64{impliedLabel.Id}) {
+				if id == label.Id {
+					return fmt.Errorf("%w: %s already implies %s", ErrImplicationCycle, impliedLabel.Name, label.Name)
+				}
+			}
+
+			_, err = tx.ExecContext(ctx,
+				`INSERT OR IGNORE INTO LabelImplication (labelId, impliedId) VALUES ($1, $2)`,
+				label.Id, impliedLabel.Id)
+			if err != nil {
+				return err
+			}
+			rules[label.Id] = append(rules[label.Id], impliedLabel.Id)
+
+			// Files with the label already have the labels it implied before
+			ids := append([]int64{impliedLabel.Id}, impliedLabelIds(rules, []int64{impliedLabel.Id})...)
+			for _, id := range ids {
+				res, err := tx.ExecContext(ctx,
+					`INSERT OR IGNORE INTO FileInfo (fileId, labelId)
+        SELECT fileId, $2 FROM FileInfo WHERE labelId = $1`,
+					label.Id, id)
+				if err != nil {
+					return err
+				}
+
+				cnt, err := res.RowsAffected()
+				if err != nil {
+					return err
+				}
+				added += cnt
+			}
+		}
+
+		return recordHistory(ctx, tx, OpAddImplications, HistoryArgs{Labels: []string{name}, Implied: implied}, changes)
+	})
+
+	return added, err
+}
+
+// DeleteLabelImplications removes the rules. Links added by them are kept.
+func (m *DB) DeleteLabelImplications(ctx context.Context, name string, implied []string) error {
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		changes, err := trackChanges(ctx, tx, labelImplicationScope)
+		if err != nil {
+			return err
+		}
+
+		for _, impliedName := range implied {
+			res, err := tx.ExecContext(ctx,
+				`DELETE FROM LabelImplication
+        WHERE labelId = (SELECT id FROM Label WHERE name = $1)
+        AND impliedId = (SELECT id FROM Label WHERE name = $2)`,
+				canonicalLabelName(tx, name), canonicalLabelName(tx, impliedName))
+			if err != nil {
+				return err
+			}
+
+			if cnt, err := res.RowsAffected(); err != nil {
+				return err
+			} else if cnt == 0 {
+				return fmt.Errorf("%w: %s -> %s", ErrImplicationNotFound, name, impliedName)
+			}
+		}
+
+		return recordHistory(ctx, tx, OpDeleteImplications, HistoryArgs{Labels: []string{name}, Implied: implied}, changes)
+	})
+
+	return err
+}
+
+// GetLabelImplications returns every rule, ordered by the names of their labels
+func (m *DB) GetLabelImplications() ([]LabelImplication, error) {
+	stmt := `SELECT Label.name AS label, Implied.name AS implied
+    FROM LabelImplication
+    JOIN Label ON Label.id = LabelImplication.labelId
+    JOIN Label AS Implied ON Implied.id = LabelImplication.impliedId
+    ORDER BY Label.name, Implied.name`
+
+	rules := []LabelImplication{}
+	err := m.db.Select(&rules, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
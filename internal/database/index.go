@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/LeBulldoge/labee/internal/content"
+	"github.com/LeBulldoge/labee/internal/os"
+	"github.com/jmoiron/sqlx"
+)
+
+// Amount of files stored per transaction
+const indexBatchSize = 100
+
+type IndexedFile struct {
+	File
+	// Modification time of the file when its contents were indexed, zero if never
+	Mtime int64 `db:"mtime"`
+}
+
+type IndexEntry struct {
+	FileId  int64
+	Mtime   int64
+	Content string
+}
+
+func (m *DB) GetIndexedFiles() ([]IndexedFile, error) {
+	stmt := `SELECT File.id, File.path, COALESCE(FileIndex.mtime, 0) AS mtime
+    FROM File
+    LEFT JOIN FileIndex ON FileIndex.fileId = File.id`
+
+	files := []IndexedFile{}
+	err := m.db.Select(&files, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+type IndexStats struct {
+	Indexed   int
+	Unchanged int
+	Skipped   int
+	// Files which couldn't be read, these are skipped as well
+	Errors []error
+}
+
+// IndexFiles indexes the contents of the stored files which changed since they were
+// last indexed, or of all of them if forced. Files which are unsupported, too large
+// or no longer exist are dropped from the index.
+func (m *DB) IndexFiles(ctx context.Context, force bool) (IndexStats, error) {
+	var stats IndexStats
+
+	files, err := m.GetIndexedFiles()
+	if err != nil {
+		return stats, err
+	}
+
+	var (
+		entries []IndexEntry
+		removed []int64
+		size    int
+	)
+
+	flush := func() error {
+		err := m.UpdateIndex(ctx, entries, removed)
+		entries, removed, size = nil, nil, 0
+		return err
+	}
+
+	for _, f := range files {
+		modTime, ok := os.FileModTime(f.Path)
+		if !ok || !content.Supported(f.Path) {
+			if f.Mtime != 0 {
+				removed = append(removed, f.Id)
+			}
+			stats.Skipped++
+			continue
+		}
+
+		mtime := modTime.UnixNano()
+		if mtime == f.Mtime && !force {
+			stats.Unchanged++
+			continue
+		}
+
+		text, err := content.Extract(f.Path)
+		if errors.Is(err, content.ErrUnsupported) || errors.Is(err, content.ErrTooLarge) {
+			if f.Mtime != 0 {
+				removed = append(removed, f.Id)
+			}
+			stats.Skipped++
+			continue
+		} else if err != nil {
+			stats.Errors = append(stats.Errors, fmt.Errorf("couldn't index %s: %w", f.Path, err))
+			stats.Skipped++
+			continue
+		}
+
+		entries = append(entries, IndexEntry{FileId: f.Id, Mtime: mtime, Content: text})
+		stats.Indexed++
+
+		size += len(text)
+		if len(entries) >= indexBatchSize || size >= content.MaxSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+
+	return stats, flush()
+}
+
+// UpdateIndex stores the contents of the entries and drops the index of removed files
+func (m *DB) UpdateIndex(ctx context.Context, entries []IndexEntry, removed []int64) error {
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		for _, id := range removed {
+			err := deleteFileIndex(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, e := range entries {
+			err := deleteFileIndex(ctx, tx, e.FileId)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `INSERT INTO FileContent (rowid, content) VALUES ($1, $2)`, e.FileId, e.Content)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `INSERT INTO FileIndex (fileId, mtime) VALUES ($1, $2)`, e.FileId, e.Mtime)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+func deleteFileIndex(ctx context.Context, tx *sqlx.Tx, fileId int64) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM FileContent WHERE rowid = ?`, fileId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM FileIndex WHERE fileId = ?`, fileId)
+
+	return err
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"strings"
+	"fmt"
+	"unicode/utf8"
 
+	"github.com/LeBulldoge/labee/internal/fuzzy"
+	"github.com/LeBulldoge/labee/internal/os"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -13,12 +16,42 @@ type Label struct {
 	Id    int64  `db:"id"`
 	Name  string `db:"name"`
 	Color string `db:"color"`
+	// Value of the link to a file, empty if none was assigned
+	Value string `db:"value"`
+	// Unix time the label was linked to a file at, 0 if unknown
+	LinkedAt int64 `db:"linked_at"`
+}
+
+// Separates the names of nested labels, e.g. "project/labee/docs"
+const LabelSeparator = "/"
+
+// labelChildrenGlob matches the names of every label nested under name
+func labelChildrenGlob(name string) string {
+	return escapeGlob(name+LabelSeparator) + "*"
 }
 
 func (m *DB) UpdateLabel(ctx context.Context, name string, newName string, newColor string) error {
 	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		args := HistoryArgs{Labels: []string{name}, Name: newName, Color: newColor}
+		changes, err := trackChanges(ctx, tx, labelScope)
+		if err != nil {
+			return err
+		}
+
+		name = canonicalLabelName(tx, name)
+
+		// Renaming a label renames the ones nested under it as well
+		args.Files, err = labelFilePaths(tx, name, len(newName) > 0)
+		if err != nil {
+			return err
+		}
+
 		if len(newName) > 0 {
-			_, err := tx.ExecContext(ctx, `UPDATE Label SET name = $1 WHERE name = $3`, newName, newColor, name)
+			if isLabelAlias(tx, newName) {
+				return fmt.Errorf("%w: %s", ErrLabelIsAlias, newName)
+			}
+
+			err := RenameLabel(ctx, tx, name, newName)
 			if err != nil {
 				return err
 			}
@@ -28,10 +61,12 @@ func (m *DB) UpdateLabel(ctx context.Context, name string, newName string, newCo
 
 		if len(newColor) > 0 {
 			err := UpsertLabel(ctx, tx, name, newColor)
-			return err
+			if err != nil {
+				return err
+			}
 		}
 
-		return nil
+		return recordHistory(ctx, tx, OpUpdateLabel, args, changes)
 	})
 
 	return err
@@ -49,7 +84,7 @@ func (m *DB) GetAllLabels() ([]Label, error) {
 
 func (m *DB) GetFileLabels(path string) ([]Label, error) {
 	stmt :=
-		`SELECT Label.* FROM Label, File
+		`SELECT Label.*, FileInfo.value, FileInfo.linked_at FROM Label, File
     JOIN FileInfo ON
     Label.id = FileInfo.labelId AND
     FileInfo.fileId = File.id
@@ -64,24 +99,35 @@ func (m *DB) GetFileLabels(path string) ([]Label, error) {
 	return labels, nil
 }
 
-func (m *DB) GetSimilarLabel(name string) *Label {
-	letters := strings.Join(strings.Split(name, ""), "%")
-
-	var label Label
-	err := m.db.Get(&label,
-		`SELECT name, color FROM Label
-        WHERE name LIKE '%`+letters+"%'")
-
+// GetSimilarLabels returns up to n labels with names closest to name, best first
+func (m *DB) GetSimilarLabels(name string, n int) []Label {
+	labels := []Label{}
+	err := m.db.Select(&labels, "SELECT id, name, color FROM Label")
 	if err != nil {
 		return nil
 	}
 
-	return &label
+	names := make([]string, len(labels))
+	for i, l := range labels {
+		names[i] = l.Name
+	}
+
+	similar := []Label{}
+	for _, i := range fuzzy.Rank(name, names, n) {
+		similar = append(similar, labels[i])
+	}
+
+	return similar
 }
 
-func getLabelId(db *sqlx.DB, name string) (int64, error) {
+// getLabelId finds the label by its name or one of its aliases
+func getLabelId(db sqlx.Queryer, name string) (int64, error) {
 	var id int64
-	err := db.Get(&id, "SELECT id FROM Label WHERE name = $1", name)
+	err := sqlx.Get(db, &id,
+		`SELECT id FROM Label WHERE name = ?
+    UNION ALL
+    SELECT labelId FROM LabelAlias WHERE name = ?`,
+		name, name)
 	return id, err
 }
 
@@ -90,6 +136,13 @@ func (m *DB) LabelExists(name string) bool {
 	return err == nil
 }
 
+// LabelHasChildren reports whether any label is nested under name
+func (m *DB) LabelHasChildren(name string) bool {
+	var id int64
+	err := m.db.Get(&id, "SELECT id FROM Label WHERE name GLOB $1", labelChildrenGlob(name))
+	return err == nil
+}
+
 func (m *DB) AddLabel(ctx context.Context, name string, color string) (*Label, error) {
 	var result *Label
 	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
@@ -120,10 +173,43 @@ func (m *DB) AddLabel(ctx context.Context, name string, color string) (*Label, e
 	return result, nil
 }
 
+var ErrLabelNotFound = errors.New("label does not exist")
+
+// DeleteLabel removes the label, or the label the alias refers to
 func (m *DB) DeleteLabel(ctx context.Context, name string) error {
 	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
-		_, err := tx.ExecContext(ctx, `DELETE FROM Label WHERE name = ?`, name)
-		return err
+		canonical := canonicalLabelName(tx, name)
+
+		var id int64
+		err := tx.GetContext(ctx, &id, `SELECT id FROM Label WHERE name = ?`, canonical)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrLabelNotFound, name)
+		} else if err != nil {
+			return err
+		}
+
+		changes, err := trackChanges(ctx, tx, labelScope, labelAliasScope, labelImplicationScope, labelInfoScope(id))
+		if err != nil {
+			return err
+		}
+
+		paths, err := labelFilePaths(tx, canonical, false)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM Label WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+
+		// Foreign keys aren't enforced, so the links are removed here
+		_, err = tx.ExecContext(ctx, `DELETE FROM FileInfo WHERE labelId = ?`, id)
+		if err != nil {
+			return err
+		}
+
+		return recordHistory(ctx, tx, OpDeleteLabel, HistoryArgs{Files: paths, Labels: []string{name}}, changes)
 	})
 
 	return err
@@ -140,7 +226,10 @@ func insertLabel(ctx context.Context, tx *sqlx.Tx, name string) (int64, error) {
 
 func getOrInsertLabel(ctx context.Context, tx *sqlx.Tx, name string) (*Label, error) {
 	var label Label
-	err := tx.GetContext(ctx, &label, `SELECT * FROM Label WHERE name = ?`, name)
+	err := tx.GetContext(ctx, &label,
+		`SELECT * FROM Label WHERE name = ?
+    OR id IN (SELECT labelId FROM LabelAlias WHERE name = ?)`,
+		name, name)
 	if err == nil {
 		return &label, nil
 	} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
@@ -167,13 +256,106 @@ func UpsertLabel(ctx context.Context, tx *sqlx.Tx, name string, color string) er
 	return err
 }
 
+// RenameLabel renames the label along with every label nested under it.
+// The nested labels go first, so that moving a label under itself doesn't
+// rename it twice.
 func RenameLabel(ctx context.Context, tx *sqlx.Tx, oldName string, newName string) error {
 	stmt :=
 		`UPDATE Label SET
+    name = $1 || substr(name, $2)
+    WHERE name GLOB $3`
+
+	_, err := tx.ExecContext(ctx, stmt, newName, utf8.RuneCountInString(oldName)+1, labelChildrenGlob(oldName))
+	if err != nil {
+		return err
+	}
+
+	stmt =
+		`UPDATE Label SET
     name = $1
     WHERE name = $2`
 
-	_, err := tx.ExecContext(ctx, stmt, newName, oldName)
+	_, err = tx.ExecContext(ctx, stmt, newName, oldName)
 
 	return err
 }
+
+type LabelUsage struct {
+	Label
+	// Amount of files the label is attached to
+	Files int
+	// Amount of those files which no longer exist
+	Missing int
+}
+
+func (m *DB) GetLabelUsage() ([]LabelUsage, error) {
+	stmt := `SELECT Label.id, Label.name, Label.color, File.path
+    FROM Label
+    LEFT JOIN FileInfo ON FileInfo.labelId = Label.id
+    LEFT JOIN File ON File.id = FileInfo.fileId
+    ORDER BY Label.name`
+
+	rows, err := m.db.Queryx(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []LabelUsage{}
+	for rows.Next() {
+		var label Label
+		var path sql.NullString
+		err := rows.Scan(&label.Id, &label.Name, &label.Color, &path)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(usage) == 0 || usage[len(usage)-1].Id != label.Id {
+			usage = append(usage, LabelUsage{Label: label})
+		}
+
+		// Links to files which were removed from the storage have no path
+		if !path.Valid {
+			continue
+		}
+
+		u := &usage[len(usage)-1]
+		u.Files++
+		if !os.FileExists(path.String) {
+			u.Missing++
+		}
+	}
+
+	return usage, rows.Err()
+}
+
+// GetOrphanedLabels returns the labels which would be left without files
+// if the files with the given ids were removed
+func (m *DB) GetOrphanedLabels(fileIds []int64) ([]Label, error) {
+	labels := []Label{}
+	if len(fileIds) == 0 {
+		return labels, nil
+	}
+
+	stmt, args, err := sqlx.In(
+		`SELECT DISTINCT Label.id, Label.name, Label.color FROM Label
+    JOIN FileInfo ON FileInfo.labelId = Label.id
+    WHERE FileInfo.fileId IN (?)
+    AND NOT EXISTS (
+      SELECT 1 FROM FileInfo AS Other
+      JOIN File ON File.id = Other.fileId
+      WHERE Other.labelId = Label.id AND Other.fileId NOT IN (?)
+    )
+    ORDER BY Label.name`,
+		fileIds, fileIds)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.db.Select(&labels, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return labels, nil
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLabelUsage(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	existing := filepath.Join(t.TempDir(), "existing")
+	if err := os.WriteFile(existing, nil, 0o644); err != nil {
+		t.Fatalf("failed writing %s: %v", existing, err)
+	}
+
+	links := map[string][]string{
+		existing:   {"a", "b"},
+		"/gone":    {"a"},
+		"/removed": {"c"},
+	}
+	for path, labels := range links {
+		if err := db.AddFilesAndLinks(ctx, []string{path}, labels); err != nil {
+			t.Fatalf("failed adding %s: %v", path, err)
+		}
+	}
+
+	// Links of removed files are left behind, but aren't counted
+	if err := db.DeleteFiles(ctx, []string{"/removed"}); err != nil {
+		t.Fatalf("failed deleting a file: %v", err)
+	}
+
+	usage, err := db.GetLabelUsage()
+	if err != nil {
+		t.Fatalf("failed getting label usage: %v", err)
+	}
+
+	type counts struct {
+		name           string
+		files, missing int
+	}
+	got := []counts{}
+	for _, u := range usage {
+		got = append(got, counts{u.Name, u.Files, u.Missing})
+	}
+
+	expected := []counts{{"a", 2, 1}, {"b", 1, 0}, {"c", 0, 0}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("label usage: got %+v, expected %+v", got, expected)
+	}
+}
+
+func TestRenameLabel(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	links := map[string][]string{
+		"/f1": {"a"},
+		"/f2": {"a/x"},
+		"/f3": {"ab"},
+	}
+	for path, labels := range links {
+		if err := db.AddFilesAndLinks(ctx, []string{path}, labels); err != nil {
+			t.Fatalf("failed adding %s: %v", path, err)
+		}
+	}
+
+	// Moving the label under itself
+	if err := db.UpdateLabel(ctx, "a", "a/b", ""); err != nil {
+		t.Fatalf("failed renaming a label: %v", err)
+	}
+
+	expected := map[string]string{"/f1": "a/b", "/f2": "a/b/x", "/f3": "ab"}
+	for path, name := range expected {
+		labels, err := db.GetFileLabels(path)
+		if err != nil {
+			t.Fatalf("failed getting labels of %s: %v", path, err)
+		}
+		if len(labels) != 1 || labels[0].Name != name {
+			t.Errorf("labels of %s: got %v, expected %s", path, labels, name)
+		}
+	}
+}
+
+func TestDeleteLabel(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	if err := db.AddFilesAndLinks(ctx, []string{"/a"}, []string{"a/b"}); err != nil {
+		t.Fatalf("failed adding a file: %v", err)
+	}
+	if _, err := db.AddLabelAliases(ctx, "a/b", []string{"ab"}, false); err != nil {
+		t.Fatalf("failed adding an alias: %v", err)
+	}
+
+	if err := db.DeleteLabel(ctx, "ab"); err != nil {
+		t.Fatalf("failed deleting a label by its alias: %v", err)
+	}
+	if db.LabelExists("a/b") {
+		t.Error("expected the label to be deleted")
+	}
+
+	if err := db.DeleteLabel(ctx, "ab"); !errors.Is(err, ErrLabelNotFound) {
+		t.Errorf("expected ErrLabelNotFound, got %v", err)
+	}
+}
+
+func TestOrphanedLabels(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	links := map[string][]string{
+		"/gone":  {"only-gone", "shared"},
+		"/other": {"shared"},
+	}
+	for path, labels := range links {
+		if err := db.AddFilesAndLinks(ctx, []string{path}, labels); err != nil {
+			t.Fatalf("failed adding %s: %v", path, err)
+		}
+	}
+
+	gone, err := db.GetFile("/gone")
+	if err != nil {
+		t.Fatalf("failed getting a file: %v", err)
+	}
+
+	orphans, err := db.GetOrphanedLabels([]int64{gone.Id})
+	if err != nil {
+		t.Fatalf("failed getting orphaned labels: %v", err)
+	}
+
+	names := []string{}
+	for _, l := range orphans {
+		names = append(names, l.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"only-gone"}) {
+		t.Errorf("orphaned labels: got %v, expected [only-gone]", names)
+	}
+}
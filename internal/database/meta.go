@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/LeBulldoge/labee/internal/os"
+	"github.com/jmoiron/sqlx"
+)
+
+// Metadata read from the file system when the file was added or refreshed.
+// Files whose metadata was never recorded have a zero ModifiedAt.
+type FileMeta struct {
+	Size int64 `db:"size"`
+	// Unix time
+	ModifiedAt int64 `db:"modified_at"`
+	// Bits of fs.FileMode
+	Mode uint32 `db:"mode"`
+	Mime string `db:"mime"`
+	// Zero where the platform doesn't provide them
+	Device int64 `db:"device"`
+	Inode  int64 `db:"inode"`
+}
+
+const (
+	TypeFile    = "file"
+	TypeDir     = "dir"
+	TypeSymlink = "symlink"
+)
+
+var (
+	ErrInvalidSize = errors.New("invalid size")
+	ErrInvalidType = errors.New("invalid file type")
+)
+
+// Columns of File selected into the File struct
+const fileColumns = "File.id, File.path, File.added_at, File.size, File.modified_at, File.mode, File.mime, File.device, File.inode"
+
+func statFileMeta(path string) (FileMeta, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return FileMeta{}, err
+	}
+
+	meta := FileMeta{
+		Size:       stat.Size,
+		ModifiedAt: stat.ModTime.Unix(),
+		Mode:       uint32(stat.Mode),
+		Mime:       stat.Mime,
+		Device:     int64(stat.Device),
+		Inode:      int64(stat.Inode),
+	}
+
+	return meta, nil
+}
+
+func updateFileMeta(ctx context.Context, tx *sqlx.Tx, fileId int64, meta FileMeta) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE File SET size = $1, modified_at = $2, mode = $3, mime = $4, device = $5, inode = $6 WHERE id = $7`,
+		meta.Size, meta.ModifiedAt, meta.Mode, meta.Mime, meta.Device, meta.Inode, fileId)
+
+	return err
+}
+
+// RefreshFiles records the current metadata of the stored files.
+// Files which no longer exist keep their last metadata and are counted as missing.
+func (m *DB) RefreshFiles(ctx context.Context, files []File) (refreshed int, missing int, err error) {
+	err = tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		for _, f := range files {
+			meta, err := statFileMeta(f.Path)
+			if err != nil {
+				missing++
+				continue
+			}
+
+			err = updateFileMeta(ctx, tx, f.Id, meta)
+			if err != nil {
+				return err
+			}
+			refreshed++
+		}
+
+		return nil
+	})
+
+	return refreshed, missing, err
+}
+
+// Multipliers of the size suffixes, in powers of 1024
+var sizeUnits = map[rune]int64{
+	'b': 1,
+	'k': 1 << 10,
+	'm': 1 << 20,
+	'g': 1 << 30,
+	't': 1 << 40,
+}
+
+// parseSize reads a size comparison the way find(1) does: +10M is more than
+// 10 MiB, -10M is less and 10M is exactly that. Plain numbers are in bytes.
+func parseSize(s string) (string, int64, error) {
+	op := "="
+	num := s
+	if strings.HasPrefix(s, "+") {
+		op, num = ">", s[1:]
+	} else if strings.HasPrefix(s, "-") {
+		op, num = "<", s[1:]
+	}
+
+	unit := int64(1)
+	if len(num) > 0 {
+		if u, ok := sizeUnits[unicode.ToLower(rune(num[len(num)-1]))]; ok {
+			unit = u
+			num = num[:len(num)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return "", 0, fmt.Errorf("%w: %s. use a size like +10M, -512k or 1G", ErrInvalidSize, s)
+	}
+
+	return op, n * unit, nil
+}
+
+// typeCondition matches the mode of the files against one of the Type* constants
+func typeCondition(b *filterBuilder, fileType string) error {
+	switch fileType {
+	case TypeDir:
+		b.add("File.mode & ? != 0", uint32(fs.ModeDir))
+	case TypeSymlink:
+		b.add("File.mode & ? != 0", uint32(fs.ModeSymlink))
+	case TypeFile:
+		// Files without metadata have no mode either
+		b.add("File.modified_at > 0 AND File.mode & ? = 0", uint32(fs.ModeType))
+	default:
+		return fmt.Errorf("%w: %s. use %s, %s or %s", ErrInvalidType, fileType, TypeDir, TypeFile, TypeSymlink)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// MoveFiles stores the file, along with everything beneath it if it's a directory,
+// under the new path, then calls rename to move it on disk. When rename fails the
+// stored paths are left untouched. Returns the amount of stored paths changed.
+func (m *DB) MoveFiles(ctx context.Context, from string, to string, rename func(from string, to string) error) (int64, error) {
+	sep := string(filepath.Separator)
+
+	var (
+		moved   bool
+		changed int64
+	)
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		var cnt int
+		err := tx.GetContext(ctx, &cnt,
+			`SELECT COUNT(*) FROM File WHERE path = $1 OR substr(path, 1, length($1) + 1) = $1 || $2`,
+			to, sep)
+		if err != nil {
+			return err
+		}
+		if cnt > 0 {
+			return fmt.Errorf("%w: %s", ErrFileAlreadyExists, to)
+		}
+
+		ids := []int64{}
+		err = tx.SelectContext(ctx, &ids,
+			`SELECT id FROM File WHERE path = $1 OR substr(path, 1, length($1) + 1) = $1 || $2`,
+			from, sep)
+		if err != nil {
+			return err
+		}
+
+		changes, err := trackChanges(ctx, tx, fileIdScope(ids))
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx,
+			`UPDATE File SET path = $2 || substr(path, length($1) + 1)
+        WHERE path = $1 OR substr(path, 1, length($1) + 1) = $1 || $3`,
+			from, to, sep)
+		if err != nil {
+			return err
+		}
+
+		changed, err = res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		err = recordHistory(ctx, tx, OpMoveFiles, HistoryArgs{Files: []string{from, to}}, changes)
+		if err != nil {
+			return err
+		}
+
+		err = rename(from, to)
+		if err != nil {
+			return err
+		}
+		moved = true
+
+		return nil
+	})
+
+	// The paths couldn't be committed, so the file goes back where it was
+	if err != nil && moved {
+		err = errors.Join(err, rename(to, from))
+	}
+
+	return changed, err
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMoveFiles(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "dir")
+	file := filepath.Join(dir, "file.txt")
+	sibling := filepath.Join(root, "dir2")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AddFilesAndLinks(ctx, []string{dir, file, sibling}, []string{"a"}); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+
+	failed := errors.New("rename failed")
+	_, err := db.MoveFiles(ctx, dir, filepath.Join(root, "new"), func(string, string) error { return failed })
+	if !errors.Is(err, failed) {
+		t.Errorf("failed rename: expected its error, got %v", err)
+	}
+
+	paths := testFilePaths(t, db, FileFilter{})
+	if !reflect.DeepEqual(paths, []string{dir, file, sibling}) {
+		t.Errorf("paths after a failed rename: got %v", paths)
+	}
+
+	if _, err := db.MoveFiles(ctx, file, sibling, os.Rename); !errors.Is(err, ErrFileAlreadyExists) {
+		t.Errorf("moving onto a stored path: expected ErrFileAlreadyExists, got %v", err)
+	}
+
+	moved := filepath.Join(root, "moved")
+	changed, err := db.MoveFiles(ctx, dir, moved, os.Rename)
+	if err != nil {
+		t.Fatalf("failed moving files: %v", err)
+	}
+	if changed != 2 {
+		t.Errorf("changed paths: got %d, expected 2", changed)
+	}
+
+	paths = testFilePaths(t, db, FileFilter{Existing: true})
+	if !reflect.DeepEqual(paths, []string{moved, filepath.Join(moved, "file.txt")}) {
+		t.Errorf("paths after moving: got %v", paths)
+	}
+}
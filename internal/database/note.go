@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SetNote attaches the note to the file, adding the file to the storage if needed.
+// An empty note removes the existing one, files which aren't stored are left alone.
+func (m *DB) SetNote(ctx context.Context, path string, text string) error {
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		paths := []string{path}
+		changes, err := trackChanges(ctx, tx, fileScope(paths), noteScope(paths))
+		if err != nil {
+			return err
+		}
+
+		if len(strings.TrimSpace(text)) == 0 {
+			_, err := tx.ExecContext(ctx,
+				`DELETE FROM Note WHERE fileId = (SELECT id FROM File WHERE path = ?)`, path)
+			if err != nil {
+				return err
+			}
+
+			return recordHistory(ctx, tx, OpSetNote, HistoryArgs{Files: paths}, changes)
+		}
+
+		fileId, err := getOrInsertFile(tx, path)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO Note (fileId, text) VALUES ($1, $2)
+        ON CONFLICT(fileId) DO UPDATE SET text=excluded.text`,
+			fileId, text)
+		if err != nil {
+			return err
+		}
+
+		return recordHistory(ctx, tx, OpSetNote, HistoryArgs{Files: paths}, changes)
+	})
+
+	return err
+}
+
+// GetNote returns the note attached to the file, or an empty string if there's none
+func (m *DB) GetNote(path string) (string, error) {
+	var text string
+	err := m.db.Get(&text,
+		`SELECT Note.text FROM Note
+    JOIN File ON File.id = Note.fileId
+    WHERE File.path = $1`,
+		path)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return text, err
+}
@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/LeBulldoge/labee/internal/os"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	RelinkByInode = "inode"
+	RelinkByHash  = "hash"
+)
+
+// A missing stored file, along with the paths it may have been moved to
+type MovedFile struct {
+	HashedFile
+	Paths []string
+	// One of the RelinkBy* constants
+	By string
+}
+
+type fileKey struct {
+	device, inode int64
+}
+
+// FindMovedFiles searches the roots for the stored files missing from their paths.
+// Files are recognized by their device and inode numbers first, then by their hashes.
+// Untracked directories are recognized by the inode numbers only.
+//
+// Moved files have exactly one new path which no other missing file was found at,
+// the rest are ambiguous.
+func (m *DB) FindMovedFiles(roots []string) (moved []MovedFile, ambiguous []MovedFile, err error) {
+	missingFiles, err := m.GetFilesWithFilter(FileFilter{Missing: true})
+	if err != nil || len(missingFiles) == 0 {
+		return nil, nil, err
+	}
+
+	ids := []int64{}
+	for _, f := range missingFiles {
+		ids = append(ids, f.Id)
+	}
+
+	missing, err := m.GetHashedFiles(ids...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byKey := map[fileKey][]int{}
+	byHash := map[string][]int{}
+	// Only files of these sizes are hashed, unless the size of a hashed file is unknown
+	sizes := map[int64]bool{}
+	hashAll := false
+	for i, f := range missing {
+		if f.Inode != 0 {
+			key := fileKey{f.Device, f.Inode}
+			byKey[key] = append(byKey[key], i)
+		}
+
+		if len(f.Hash) > 0 {
+			byHash[f.Hash] = append(byHash[f.Hash], i)
+			if f.ModifiedAt != 0 {
+				sizes[f.Size] = true
+			} else {
+				hashAll = true
+			}
+		}
+	}
+
+	tracked := map[string]bool{}
+	paths := []string{}
+	err = m.db.Select(&paths, `SELECT path FROM File`)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range paths {
+		tracked[p] = true
+	}
+
+	byInode := map[int][]string{}
+	byContent := map[int][]string{}
+	seen := map[string]bool{}
+
+	visit := func(path string, d fs.DirEntry, err error) error {
+		// Unreadable entries are skipped
+		if err != nil || tracked[path] || seen[path] {
+			return nil
+		}
+		seen[path] = true
+
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		device, inode := os.FileId(info)
+		found := false
+		for _, i := range byKey[fileKey{int64(device), int64(inode)}] {
+			// Inodes of removed files get reused
+			if f := missing[i]; f.ModifiedAt == 0 || f.Size == info.Size() || d.IsDir() {
+				byInode[i] = append(byInode[i], path)
+				found = true
+			}
+		}
+
+		if found || d.IsDir() || len(byHash) == 0 || !(hashAll || sizes[info.Size()]) {
+			return nil
+		}
+
+		hash, err := os.Hash(path)
+		if err != nil {
+			return nil
+		}
+		for _, i := range byHash[hash] {
+			byContent[i] = append(byContent[i], path)
+		}
+
+		return nil
+	}
+
+	for _, root := range roots {
+		err := filepath.WalkDir(root, visit)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	candidates := []MovedFile{}
+	claims := map[string]int{}
+	for i, f := range missing {
+		c := MovedFile{HashedFile: f, Paths: byInode[i], By: RelinkByInode}
+		if len(c.Paths) == 0 {
+			c.Paths, c.By = byContent[i], RelinkByHash
+		}
+		if len(c.Paths) == 0 {
+			continue
+		}
+
+		for _, p := range c.Paths {
+			claims[p]++
+		}
+		candidates = append(candidates, c)
+	}
+
+	for _, c := range candidates {
+		if len(c.Paths) == 1 && claims[c.Paths[0]] == 1 {
+			moved = append(moved, c)
+		} else {
+			ambiguous = append(ambiguous, c)
+		}
+	}
+
+	return moved, ambiguous, nil
+}
+
+// RelinkFiles stores the moved files under their new paths, keeping their labels,
+// notes and indexed contents. Their metadata is recorded anew.
+func (m *DB) RelinkFiles(ctx context.Context, moved []MovedFile) error {
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		ids := []int64{}
+		args := HistoryArgs{}
+		for _, f := range moved {
+			ids = append(ids, f.Id)
+			args.Files = append(args.Files, f.Path, f.Paths[0])
+		}
+
+		changes, err := trackChanges(ctx, tx, fileIdScope(ids))
+		if err != nil {
+			return err
+		}
+
+		for _, f := range moved {
+			path := f.Paths[0]
+
+			var cnt int
+			err := tx.GetContext(ctx, &cnt, `SELECT COUNT(*) FROM File WHERE path = ?`, path)
+			if err != nil {
+				return err
+			}
+			if cnt > 0 {
+				return fmt.Errorf("%w: %s", ErrFileAlreadyExists, path)
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE File SET path = $1 WHERE id = $2`, path, f.Id)
+			if err != nil {
+				return err
+			}
+
+			if meta, err := statFileMeta(path); err == nil {
+				err = updateFileMeta(ctx, tx, f.Id, meta)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return recordHistory(ctx, tx, OpRelinkFiles, args, changes)
+	})
+
+	return err
+}
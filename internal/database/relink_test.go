@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	ios "github.com/LeBulldoge/labee/internal/os"
+)
+
+func TestRelinkFiles(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	root := t.TempDir()
+	renamed := filepath.Join(root, "renamed.txt")
+	copied := filepath.Join(root, "copied.txt")
+	for _, path := range []string{renamed, copied} {
+		if err := os.WriteFile(path, []byte(path), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.AddFilesAndLinks(ctx, []string{renamed, copied}, []string{"keep"}); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+
+	files, err := db.GetHashedFiles()
+	if err != nil {
+		t.Fatalf("failed getting files: %v", err)
+	}
+	entries := []HashEntry{}
+	for _, f := range files {
+		hash, err := ios.Hash(f.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, HashEntry{FileId: f.Id, Hash: hash, Mtime: 1})
+	}
+	if err := db.UpdateHashes(ctx, entries); err != nil {
+		t.Fatalf("failed updating hashes: %v", err)
+	}
+
+	dir := filepath.Join(root, "moved")
+	if err := os.Mkdir(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	// Renames keep the inode, copies only keep the contents
+	newRenamed := filepath.Join(dir, "renamed.txt")
+	if err := os.Rename(renamed, newRenamed); err != nil {
+		t.Fatal(err)
+	}
+	newCopied := filepath.Join(dir, "copied.txt")
+	if err := os.WriteFile(newCopied, []byte(copied), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(copied); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, ambiguous, err := db.FindMovedFiles([]string{root})
+	if err != nil {
+		t.Fatalf("failed finding moved files: %v", err)
+	}
+	if len(ambiguous) > 0 {
+		t.Errorf("ambiguous files: got %+v", ambiguous)
+	}
+
+	found := map[string]string{}
+	for _, f := range moved {
+		if len(f.Paths) != 1 {
+			t.Fatalf("moved file with several paths: %+v", f)
+		}
+		found[f.Path] = f.Paths[0] + " by " + f.By
+	}
+	expected := map[string]string{
+		renamed: newRenamed + " by " + RelinkByInode,
+		copied:  newCopied + " by " + RelinkByHash,
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("moved files: got %v, expected %v", found, expected)
+	}
+
+	if err := db.RelinkFiles(ctx, moved); err != nil {
+		t.Fatalf("failed relinking files: %v", err)
+	}
+
+	paths := testFilePaths(t, db, FileFilter{Labels: []string{"keep"}, Existing: true})
+	if !reflect.DeepEqual(paths, []string{newCopied, newRenamed}) {
+		t.Errorf("relinked files: got %v, expected [%s %s]", paths, newCopied, newRenamed)
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
-const TargetVersion = 2
+const TargetVersion = 14
 
 type migration struct {
 	up   func(context.Context, *sqlx.Tx) error
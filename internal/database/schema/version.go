@@ -7,8 +7,384 @@ import (
 )
 
 var versionMap = map[int](func() migration){
-	2: version2,
-	1: version1,
+	14: version14,
+	13: version13,
+	12: version12,
+	11: version11,
+	10: version10,
+	9:  version9,
+	8:  version8,
+	7:  version7,
+	6:  version6,
+	5:  version5,
+	4:  version4,
+	3:  version3,
+	2:  version2,
+	1:  version1,
+}
+
+// Statements reverting the changes, and when they were reverted
+func version14() migration {
+	up := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `ALTER TABLE History ADD COLUMN undo TEXT DEFAULT '' NOT NULL;
+ALTER TABLE History ADD COLUMN undone_at INTEGER DEFAULT 0 NOT NULL;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `ALTER TABLE History DROP COLUMN undone_at;
+ALTER TABLE History DROP COLUMN undo;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	return migration{up: up, down: down}
+}
+
+// Log of the changes made to files and labels
+func version13() migration {
+	up := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `CREATE TABLE History (
+  id   INTEGER NOT NULL
+               UNIQUE,
+  op   TEXT    NOT NULL,
+  args TEXT    NOT NULL,
+  at   INTEGER NOT NULL
+               DEFAULT (unixepoch()),
+  user TEXT    NOT NULL
+               DEFAULT '',
+  PRIMARY KEY (
+      id AUTOINCREMENT
+  )
+);
+
+CREATE INDEX HistoryAt ON History (at);`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `DROP INDEX HistoryAt;
+DROP TABLE History;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	return migration{up: up, down: down}
+}
+
+// Device and inode numbers, to find files after they were moved
+func version12() migration {
+	up := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `ALTER TABLE File ADD COLUMN device INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE File ADD COLUMN inode INTEGER DEFAULT 0 NOT NULL;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `ALTER TABLE File DROP COLUMN inode;
+ALTER TABLE File DROP COLUMN device;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	return migration{up: up, down: down}
+}
+
+// Content hashes of the files, along with their modification time when hashed
+func version11() migration {
+	up := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `ALTER TABLE File ADD COLUMN hash TEXT DEFAULT '' NOT NULL;
+ALTER TABLE File ADD COLUMN hashed_mtime INTEGER DEFAULT 0 NOT NULL;
+
+CREATE INDEX FileHash ON File (hash);`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `DROP INDEX FileHash;
+ALTER TABLE File DROP COLUMN hashed_mtime;
+ALTER TABLE File DROP COLUMN hash;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	return migration{up: up, down: down}
+}
+
+// Metadata of the files, recorded when they are added or refreshed
+func version10() migration {
+	up := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `ALTER TABLE File ADD COLUMN size INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE File ADD COLUMN modified_at INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE File ADD COLUMN mode INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE File ADD COLUMN mime TEXT DEFAULT '' NOT NULL;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `ALTER TABLE File DROP COLUMN mime;
+ALTER TABLE File DROP COLUMN mode;
+ALTER TABLE File DROP COLUMN modified_at;
+ALTER TABLE File DROP COLUMN size;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	return migration{up: up, down: down}
+}
+
+// Unix times files were added and labels were linked at.
+// Rows from before the migration have no time, which is stored as 0
+func version9() migration {
+	up := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `ALTER TABLE File ADD COLUMN added_at INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE FileInfo ADD COLUMN linked_at INTEGER DEFAULT 0 NOT NULL;
+
+CREATE TRIGGER FileAddedAt AFTER INSERT ON File WHEN new.added_at = 0
+BEGIN
+  UPDATE File SET added_at = unixepoch() WHERE id = new.id;
+END;
+
+CREATE TRIGGER FileInfoLinkedAt AFTER INSERT ON FileInfo WHEN new.linked_at = 0
+BEGIN
+  UPDATE FileInfo SET linked_at = unixepoch() WHERE fileId = new.fileId AND labelId = new.labelId;
+END;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `DROP TRIGGER FileInfoLinkedAt;
+DROP TRIGGER FileAddedAt;
+ALTER TABLE FileInfo DROP COLUMN linked_at;
+ALTER TABLE File DROP COLUMN added_at;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	return migration{up: up, down: down}
+}
+
+// Labels implied by other labels, e.g. invoice implies finance
+func version8() migration {
+	up := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `CREATE TABLE LabelImplication (
+  labelId   INTEGER NOT NULL
+                    REFERENCES Label (id) ON DELETE CASCADE,
+  impliedId INTEGER NOT NULL
+                    REFERENCES Label (id) ON DELETE CASCADE,
+  PRIMARY KEY (
+      labelId,
+      impliedId
+  )
+);
+
+CREATE TRIGGER LabelImplicationDelete AFTER DELETE ON Label
+BEGIN
+  DELETE FROM LabelImplication WHERE labelId = old.id OR impliedId = old.id;
+END;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `DROP TRIGGER LabelImplicationDelete;
+DROP TABLE LabelImplication;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	return migration{up: up, down: down}
+}
+
+// Notes attached to files
+func version7() migration {
+	up := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `CREATE TABLE Note (
+  fileId INTEGER NOT NULL
+                 UNIQUE
+                 REFERENCES File (id) ON DELETE CASCADE,
+  text   TEXT    NOT NULL,
+  PRIMARY KEY (
+      fileId
+  )
+);
+
+CREATE TRIGGER NoteDelete AFTER DELETE ON File
+BEGIN
+  DELETE FROM Note WHERE fileId = old.id;
+END;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `DROP TRIGGER NoteDelete;
+DROP TABLE Note;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	return migration{up: up, down: down}
+}
+
+// Alternative names of labels
+func version6() migration {
+	up := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `CREATE TABLE LabelAlias (
+  name    TEXT    NOT NULL
+                  UNIQUE,
+  labelId INTEGER NOT NULL
+                  REFERENCES Label (id) ON DELETE CASCADE,
+  PRIMARY KEY (
+      name
+  )
+);
+
+CREATE TRIGGER LabelAliasDelete AFTER DELETE ON Label
+BEGIN
+  DELETE FROM LabelAlias WHERE labelId = old.id;
+END;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `DROP TRIGGER LabelAliasDelete;
+DROP TABLE LabelAlias;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	return migration{up: up, down: down}
+}
+
+// Values on links, e.g. priority=2. The column has no type affinity,
+// so numbers and text keep their type for comparisons
+func version5() migration {
+	up := func(ctx context.Context, tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `ALTER TABLE FileInfo ADD COLUMN value DEFAULT '' NOT NULL;`)
+
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `ALTER TABLE FileInfo DROP COLUMN value;`)
+
+		return err
+	}
+
+	return migration{up: up, down: down}
+}
+
+// Full-text index of file contents
+func version4() migration {
+	up := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `CREATE VIRTUAL TABLE FileContent USING fts5 (
+  content
+);
+
+CREATE TABLE FileIndex (
+  fileId INTEGER NOT NULL
+                 UNIQUE
+                 REFERENCES File (id) ON DELETE CASCADE,
+  mtime  INTEGER NOT NULL,
+  PRIMARY KEY (
+      fileId
+  )
+);
+
+CREATE TRIGGER FileIndexDelete AFTER DELETE ON File
+BEGIN
+  DELETE FROM FileContent WHERE rowid = old.id;
+  DELETE FROM FileIndex WHERE fileId = old.id;
+END;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `DROP TRIGGER FileIndexDelete;
+DROP TABLE FileIndex;
+DROP TABLE FileContent;`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	return migration{up: up, down: down}
+}
+
+// Saved searches, usable as virtual labels
+func version3() migration {
+	up := func(ctx context.Context, tx *sqlx.Tx) error {
+		stmt := `CREATE TABLE SavedSearch (
+  id     INTEGER NOT NULL
+                 UNIQUE,
+  name   TEXT    NOT NULL
+                 UNIQUE,
+  filter TEXT    NOT NULL,
+  PRIMARY KEY (
+      id AUTOINCREMENT
+  )
+);`
+
+		_, err := tx.ExecContext(ctx, stmt)
+
+		return err
+	}
+
+	down := func(ctx context.Context, tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `DROP TABLE SavedSearch;`)
+
+		return err
+	}
+
+	return migration{up: up, down: down}
 }
 
 // Add default value to color to not have to deal with sql NULLs
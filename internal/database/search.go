@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Prefix which marks a saved search when used in place of a label
+const SavedSearchPrefix = "@"
+
+type SavedSearch struct {
+	Id     int64  `db:"id"`
+	Name   string `db:"name"`
+	Filter FileFilter
+}
+
+var (
+	ErrSavedSearchNotFound  = errors.New("saved search does not exist")
+	ErrSavedSearchRecursive = errors.New("saved search refers to itself")
+)
+
+// IsSavedSearch reports whether a label name refers to a saved search
+func IsSavedSearch(label string) bool {
+	return strings.HasPrefix(label, SavedSearchPrefix)
+}
+
+func (m *DB) SaveSearch(ctx context.Context, name string, filter FileFilter) error {
+	data, err := json.Marshal(filter)
+	if err != nil {
+		return err
+	}
+
+	err = tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		changes, err := trackChanges(ctx, tx, savedSearchScope)
+		if err != nil {
+			return err
+		}
+
+		stmt :=
+			`INSERT INTO SavedSearch (name, filter) VALUES ($1, $2)
+        ON CONFLICT(name) DO UPDATE SET filter=excluded.filter`
+
+		_, err = tx.ExecContext(ctx, stmt, name, string(data))
+		if err != nil {
+			return err
+		}
+
+		return recordHistory(ctx, tx, OpSaveSearch, HistoryArgs{Search: name}, changes)
+	})
+
+	return err
+}
+
+func (m *DB) DeleteSavedSearch(ctx context.Context, name string) error {
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		changes, err := trackChanges(ctx, tx, savedSearchScope)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM SavedSearch WHERE name = ?`, name)
+		if err != nil {
+			return err
+		}
+
+		if cnt, err := res.RowsAffected(); err != nil {
+			return err
+		} else if cnt == 0 {
+			return fmt.Errorf("%w: %s", ErrSavedSearchNotFound, name)
+		}
+
+		return recordHistory(ctx, tx, OpDeleteSearch, HistoryArgs{Search: name}, changes)
+	})
+
+	return err
+}
+
+type savedSearchRow struct {
+	Id     int64  `db:"id"`
+	Name   string `db:"name"`
+	Filter string `db:"filter"`
+}
+
+func (r savedSearchRow) toSavedSearch() (SavedSearch, error) {
+	search := SavedSearch{Id: r.Id, Name: r.Name}
+	err := json.Unmarshal([]byte(r.Filter), &search.Filter)
+
+	return search, err
+}
+
+func getSavedSearch(db sqlx.Queryer, name string) (*SavedSearch, error) {
+	var row savedSearchRow
+	err := sqlx.Get(db, &row, `SELECT * FROM SavedSearch WHERE name = $1`, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrSavedSearchNotFound, name)
+	} else if err != nil {
+		return nil, fmt.Errorf("couldn't get saved search %s: %w", name, err)
+	}
+
+	search, err := row.toSavedSearch()
+	if err != nil {
+		return nil, err
+	}
+
+	return &search, nil
+}
+
+func (m *DB) GetSavedSearch(name string) (*SavedSearch, error) {
+	return getSavedSearch(m.db, name)
+}
+
+func (m *DB) GetAllSavedSearches() ([]SavedSearch, error) {
+	rows := []savedSearchRow{}
+	err := m.db.Select(&rows, `SELECT * FROM SavedSearch ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+
+	searches := []SavedSearch{}
+	for _, row := range rows {
+		search, err := row.toSavedSearch()
+		if err != nil {
+			return nil, err
+		}
+
+		searches = append(searches, search)
+	}
+
+	return searches, nil
+}
+
+func (m *DB) SavedSearchExists(name string) bool {
+	var id int64
+	err := m.db.Get(&id, `SELECT id FROM SavedSearch WHERE name = $1`, name)
+	return err == nil
+}
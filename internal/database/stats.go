@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+
+	"github.com/LeBulldoge/labee/internal/os"
+)
+
+type DirectoryCount struct {
+	Path  string `json:"path"`
+	Files int    `json:"files"`
+}
+
+type Stats struct {
+	Files    int `json:"files"`
+	Labels   int `json:"labels"`
+	Links    int `json:"links"`
+	Untagged int `json:"untagged"`
+	Missing  int `json:"missing"`
+	// Directories containing the most stored files
+	Directories []DirectoryCount `json:"directories"`
+}
+
+// Links which point to removed files or labels are not counted
+const validLinks = `FileInfo
+    JOIN File ON File.id = FileInfo.fileId
+    JOIN Label ON Label.id = FileInfo.labelId`
+
+// Files without any labels, including the inherited ones
+var untaggedCond, _ = ownedCondition("1")
+
+// GetStats counts the stored files, labels and links. Up to topDirs directories
+// are returned, sorted by the amount of files directly inside them.
+func (m *DB) GetStats(ctx context.Context, topDirs int) (*Stats, error) {
+	var stats Stats
+
+	counts := []struct {
+		dest *int
+		stmt string
+	}{
+		{&stats.Labels, `SELECT COUNT(*) FROM Label`},
+		{&stats.Links, `SELECT COUNT(*) FROM ` + validLinks},
+		{&stats.Untagged, `SELECT COUNT(*) FROM File WHERE NOT ` + untaggedCond},
+	}
+
+	for _, c := range counts {
+		err := m.db.GetContext(ctx, c.dest, c.stmt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	paths := []string{}
+	err := m.db.SelectContext(ctx, &paths, `SELECT path FROM File`)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := map[string]int{}
+	for _, path := range paths {
+		if !os.FileExists(path) {
+			stats.Missing++
+		}
+		dirs[filepath.Dir(path)]++
+	}
+	stats.Files = len(paths)
+
+	stats.Directories = []DirectoryCount{}
+	for dir, cnt := range dirs {
+		stats.Directories = append(stats.Directories, DirectoryCount{Path: dir, Files: cnt})
+	}
+
+	sort.Slice(stats.Directories, func(i, j int) bool {
+		a, b := stats.Directories[i], stats.Directories[j]
+		if a.Files == b.Files {
+			return a.Path < b.Path
+		}
+		return a.Files > b.Files
+	})
+
+	if topDirs < 0 {
+		topDirs = 0
+	}
+	if len(stats.Directories) > topDirs {
+		stats.Directories = stats.Directories[:topDirs]
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing")
+	if err := os.WriteFile(existing, nil, 0o644); err != nil {
+		t.Fatalf("failed writing %s: %v", existing, err)
+	}
+
+	links := map[string][]string{
+		existing:    {"a", "b"},
+		"/x/1":      {"a"},
+		"/x/2":      {"b"},
+		"/x/3":      nil,
+		"/y/1":      nil,
+		"/removed":  {"c"},
+		"/a/tagged": {"a"},
+	}
+	for path, labels := range links {
+		if err := db.AddFilesAndLinks(ctx, []string{path}, labels); err != nil {
+			t.Fatalf("failed adding %s: %v", path, err)
+		}
+	}
+	if err := db.DeleteFiles(ctx, []string{"/removed"}); err != nil {
+		t.Fatalf("failed deleting a file: %v", err)
+	}
+
+	stats, err := db.GetStats(ctx, 2)
+	if err != nil {
+		t.Fatalf("failed getting stats: %v", err)
+	}
+
+	// Links of the removed file aren't counted
+	expected := Stats{
+		Files:    6,
+		Labels:   3,
+		Links:    5,
+		Untagged: 2,
+		Missing:  5,
+		// Directories with as many files are sorted by their paths
+		Directories: []DirectoryCount{
+			{Path: "/x", Files: 3},
+			{Path: "/a", Files: 1},
+		},
+	}
+	if !reflect.DeepEqual(*stats, expected) {
+		t.Errorf("stats: got %+v, expected %+v", *stats, expected)
+	}
+
+	for _, top := range []int{0, -1} {
+		stats, err := db.GetStats(ctx, top)
+		if err != nil {
+			t.Fatalf("failed getting stats: %v", err)
+		}
+		if len(stats.Directories) != 0 {
+			t.Errorf("top %d: got %v", top, stats.Directories)
+		}
+	}
+}
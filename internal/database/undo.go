@@ -0,0 +1,379 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrCannotUndo    = errors.New("change can't be undone")
+)
+
+// A statement reverting a part of a change
+type undoStmt struct {
+	Stmt string `json:"stmt"`
+	Args []any  `json:"args"`
+}
+
+// Rows of a table which an operation may change
+type rowScope struct {
+	table string
+	// Columns to read, all of them if empty
+	columns string
+	// Columns identifying a row
+	keys  []string
+	where string
+	args  []any
+}
+
+// Labels along with their aliases and implication rules, and saved searches
+// are few, so they're tracked whole
+var (
+	labelScope            = rowScope{table: "Label", keys: []string{"id"}, where: "1"}
+	labelAliasScope       = rowScope{table: "LabelAlias", keys: []string{"name"}, where: "1"}
+	labelImplicationScope = rowScope{table: "LabelImplication", keys: []string{"labelId", "impliedId"}, where: "1"}
+	savedSearchScope      = rowScope{table: "SavedSearch", keys: []string{"id"}, where: "1"}
+)
+
+// Columns which triggers stamp with the current time when a row is inserted with 0
+var insertStamps = map[string][]string{
+	"File":     {"added_at"},
+	"FileInfo": {"linked_at"},
+}
+
+// Condition matching the ids of the files stored under the paths
+const storedFileIds = "SELECT id FROM File WHERE path IN (SELECT value FROM json_each(?))"
+
+func fileScope(paths []string) rowScope {
+	return rowScope{table: "File", keys: []string{"id"}, where: "id IN (" + storedFileIds + ")", args: []any{jsonList(paths)}}
+}
+
+func fileInfoScope(paths []string) rowScope {
+	return rowScope{table: "FileInfo", keys: []string{"fileId", "labelId"}, where: "fileId IN (" + storedFileIds + ")", args: []any{jsonList(paths)}}
+}
+
+func noteScope(paths []string) rowScope {
+	return rowScope{table: "Note", keys: []string{"fileId"}, where: "fileId IN (" + storedFileIds + ")", args: []any{jsonList(paths)}}
+}
+
+// Condition matching the ids in the list
+const listedIds = "SELECT value FROM json_each(?)"
+
+// Unlike paths, the ids still match the rows once the files are moved or removed
+func fileIdScope(ids []int64) rowScope {
+	return rowScope{table: "File", keys: []string{"id"}, where: "id IN (" + listedIds + ")", args: []any{jsonList(ids)}}
+}
+
+func fileLinkScope(ids []int64) rowScope {
+	return rowScope{table: "FileInfo", keys: []string{"fileId", "labelId"}, where: "fileId IN (" + listedIds + ")", args: []any{jsonList(ids)}}
+}
+
+// storedFileScopes cover everything stored for the files
+func storedFileScopes(ids []int64) []rowScope {
+	args := []any{jsonList(ids)}
+
+	return []rowScope{
+		fileIdScope(ids),
+		fileLinkScope(ids),
+		{table: "Note", keys: []string{"fileId"}, where: "fileId IN (" + listedIds + ")", args: args},
+		{table: "FileIndex", keys: []string{"fileId"}, where: "fileId IN (" + listedIds + ")", args: args},
+		{table: "FileContent", columns: "rowid, content", keys: []string{"rowid"}, where: "rowid IN (" + listedIds + ")", args: args},
+	}
+}
+
+func labelInfoScope(labelId int64) rowScope {
+	return rowScope{table: "FileInfo", keys: []string{"fileId", "labelId"}, where: "labelId = ?", args: []any{labelId}}
+}
+
+// labelFilesScope covers the links of the files the labels or aliases are attached to.
+// The files are found before the change, so links moved off the labels are covered as well.
+func labelFilesScope(ctx context.Context, tx *sqlx.Tx, names []string) (rowScope, error) {
+	resolved := []string{}
+	for _, name := range names {
+		resolved = append(resolved, name, canonicalLabelName(tx, name))
+	}
+
+	ids := []int64{}
+	err := tx.SelectContext(ctx, &ids,
+		`SELECT DISTINCT FileInfo.fileId FROM FileInfo
+    JOIN Label ON Label.id = FileInfo.labelId
+    WHERE Label.name IN (`+listedIds+`)`,
+		jsonList(resolved))
+	if err != nil {
+		return rowScope{}, err
+	}
+
+	return fileLinkScope(ids), nil
+}
+
+// Rows of a scope by their keys
+type rowSet map[string]map[string]any
+
+// jsonList encodes the values for use with json_each, which unlike IN accepts empty lists
+func jsonList(values any) string {
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+func (s rowScope) snapshot(ctx context.Context, tx *sqlx.Tx) (rowSet, error) {
+	columns := s.columns
+	if len(columns) == 0 {
+		columns = "*"
+	}
+
+	rows, err := tx.QueryxContext(ctx, "SELECT "+columns+" FROM "+s.table+" WHERE "+s.where, s.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	set := rowSet{}
+	for rows.Next() {
+		row := map[string]any{}
+		err := rows.MapScan(row)
+		if err != nil {
+			return nil, err
+		}
+		set[s.key(row)] = row
+	}
+
+	return set, rows.Err()
+}
+
+func (s rowScope) key(row map[string]any) string {
+	values := []any{}
+	for _, k := range s.keys {
+		values = append(values, row[k])
+	}
+
+	return jsonList(values)
+}
+
+// keyCondition matches the row by its keys
+func (s rowScope) keyCondition(row map[string]any) (string, []any) {
+	conds := []string{}
+	args := []any{}
+	for _, k := range s.keys {
+		conds = append(conds, k+" = ?")
+		args = append(args, row[k])
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+// revert returns the statements turning the rows after a change back into the rows before it
+func (s rowScope) revert(before rowSet, after rowSet) []undoStmt {
+	var stmts []undoStmt
+
+	keys := []string{}
+	for k := range after {
+		keys = append(keys, k)
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		old, existed := before[k]
+		row, exists := after[k]
+
+		switch {
+		case !existed:
+			cond, args := s.keyCondition(row)
+			stmts = append(stmts, undoStmt{Stmt: "DELETE FROM " + s.table + " WHERE " + cond, Args: args})
+		case !exists:
+			cols := sortedColumns(old)
+			args := []any{}
+			for _, c := range cols {
+				args = append(args, old[c])
+			}
+			stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+				s.table, strings.Join(cols, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", "))
+			stmts = append(stmts, undoStmt{Stmt: stmt, Args: args})
+
+			// Stamps are set back after the triggers which set them for new rows
+			for _, c := range insertStamps[s.table] {
+				if _, ok := old[c]; !ok {
+					continue
+				}
+				cond, keyArgs := s.keyCondition(old)
+				stmt := "UPDATE " + s.table + " SET " + c + " = ? WHERE " + cond
+				stmts = append(stmts, undoStmt{Stmt: stmt, Args: append([]any{old[c]}, keyArgs...)})
+			}
+		default:
+			sets := []string{}
+			args := []any{}
+			for _, c := range sortedColumns(old) {
+				if !reflect.DeepEqual(old[c], row[c]) {
+					sets = append(sets, c+" = ?")
+					args = append(args, old[c])
+				}
+			}
+			if len(sets) == 0 {
+				continue
+			}
+			cond, keyArgs := s.keyCondition(old)
+			stmt := "UPDATE " + s.table + " SET " + strings.Join(sets, ", ") + " WHERE " + cond
+			stmts = append(stmts, undoStmt{Stmt: stmt, Args: append(args, keyArgs...)})
+		}
+	}
+
+	return stmts
+}
+
+func sortedColumns(row map[string]any) []string {
+	cols := []string{}
+	for c := range row {
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+
+	return cols
+}
+
+// Snapshots of the rows an operation may change, taken before making it
+type changeTracker struct {
+	scopes []rowScope
+	before []rowSet
+}
+
+func trackChanges(ctx context.Context, tx *sqlx.Tx, scopes ...rowScope) (*changeTracker, error) {
+	c := &changeTracker{scopes: scopes}
+	for _, s := range scopes {
+		set, err := s.snapshot(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		c.before = append(c.before, set)
+	}
+
+	return c, nil
+}
+
+// undo compares the rows against their snapshots, returning the statements reverting the changes.
+// Scopes are reverted last to first, so files and labels, which triggers remove the rows referring
+// to when they're removed, are to be tracked before those rows.
+func (c *changeTracker) undo(ctx context.Context, tx *sqlx.Tx) ([]undoStmt, error) {
+	stmts := []undoStmt{}
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		s := c.scopes[i]
+		after, err := s.snapshot(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s.revert(c.before[i], after)...)
+	}
+
+	return stmts, nil
+}
+
+// decodeUndo reads the statements, keeping integers apart from real numbers
+func decodeUndo(data string) ([]undoStmt, error) {
+	dec := json.NewDecoder(bytes.NewBufferString(data))
+	dec.UseNumber()
+
+	var stmts []undoStmt
+	err := dec.Decode(&stmts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stmt := range stmts {
+		for i, arg := range stmt.Args {
+			n, ok := arg.(json.Number)
+			if !ok {
+				continue
+			}
+			if v, err := n.Int64(); err == nil {
+				stmt.Args[i] = v
+			} else if v, err := n.Float64(); err == nil {
+				stmt.Args[i] = v
+			}
+		}
+	}
+
+	return stmts, nil
+}
+
+// UndoChanges reverts the latest n recorded changes which weren't undone yet, latest first.
+// Files moved on disk are moved back by calling rename, and are moved again if the changes
+// can't be committed. Changes recorded before undo was supported can't be undone.
+// Returns the undone changes.
+func (m *DB) UndoChanges(ctx context.Context, n int, rename func(from string, to string) error) ([]HistoryEntry, error) {
+	entries := []HistoryEntry{}
+	// Pairs of the paths moved back and where they were moved from
+	var renamed [][2]string
+	err := tx(ctx, m.db, func(ctx context.Context, tx *sqlx.Tx) error {
+		rows := []historyRow{}
+		err := tx.SelectContext(ctx, &rows,
+			`SELECT * FROM History WHERE undone_at = 0 ORDER BY id DESC LIMIT ?`, n)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return ErrNothingToUndo
+		}
+
+		for _, r := range rows {
+			entry, err := r.toHistoryEntry()
+			if err != nil {
+				return err
+			}
+
+			if len(r.Undo) == 0 {
+				return fmt.Errorf("%w: %s was made before undo was supported", ErrCannotUndo, entry.Op)
+			}
+
+			stmts, err := decodeUndo(r.Undo)
+			if err != nil {
+				return err
+			}
+
+			for _, stmt := range stmts {
+				_, err := tx.ExecContext(ctx, stmt.Stmt, stmt.Args...)
+				if err != nil {
+					return fmt.Errorf("%w: %s: %w", ErrCannotUndo, entry.Op, err)
+				}
+			}
+
+			if entry.Op == OpMoveFiles {
+				from, to := entry.Args.Files[1], entry.Args.Files[0]
+				err := rename(from, to)
+				if err != nil {
+					return fmt.Errorf("%w: %s: %w", ErrCannotUndo, entry.Op, err)
+				}
+				renamed = append(renamed, [2]string{to, from})
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE History SET undone_at = unixepoch() WHERE id = ?`, r.Id)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, entry)
+		}
+
+		return nil
+	})
+
+	// The changes weren't undone, so the files go back where they were
+	if err != nil {
+		for i := len(renamed) - 1; i >= 0; i-- {
+			err = errors.Join(err, rename(renamed[i][0], renamed[i][1]))
+		}
+	}
+
+	return entries, err
+}
@@ -0,0 +1,330 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testDump returns every row of the tables changed by the recorded operations
+func testDump(t *testing.T, db *DB) []string {
+	t.Helper()
+
+	dump := []string{}
+	for _, table := range []string{"File", "FileInfo", "Label", "LabelAlias", "LabelImplication", "Note", "FileIndex", "FileContent", "SavedSearch"} {
+		columns := "*"
+		if table == "FileContent" {
+			columns = "rowid, content"
+		}
+
+		rows, err := db.db.Queryx("SELECT " + columns + " FROM " + table)
+		if err != nil {
+			t.Fatalf("failed reading %s: %v", table, err)
+		}
+
+		for rows.Next() {
+			row := map[string]any{}
+			if err := rows.MapScan(row); err != nil {
+				t.Fatalf("failed reading %s: %v", table, err)
+			}
+			dump = append(dump, fmt.Sprintf("%s %v", table, row))
+		}
+		rows.Close()
+	}
+	sort.Strings(dump)
+
+	return dump
+}
+
+func testFileId(t *testing.T, db *DB, path string) int64 {
+	t.Helper()
+
+	var id int64
+	if err := db.db.Get(&id, "SELECT id FROM File WHERE path = ?", path); err != nil {
+		t.Fatalf("failed getting %s: %v", path, err)
+	}
+
+	return id
+}
+
+func TestUndo(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	if err := db.AddFilesAndLinks(ctx, []string{"/a", "/b"}, []string{"x", "priority=2", "ratio=0.5"}); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+	if err := db.UpdateLabel(ctx, "x", "", "#00ff00"); err != nil {
+		t.Fatalf("failed updating a label: %v", err)
+	}
+	if _, err := db.AddLabelAliases(ctx, "x", []string{"ex"}, false); err != nil {
+		t.Fatalf("failed adding an alias: %v", err)
+	}
+	if _, err := db.AddLabelImplications(ctx, "x", []string{"y"}); err != nil {
+		t.Fatalf("failed adding an implication: %v", err)
+	}
+	if err := db.SetNote(ctx, "/a", "remember"); err != nil {
+		t.Fatalf("failed setting a note: %v", err)
+	}
+	if err := db.UpdateIndex(ctx, []IndexEntry{{FileId: testFileId(t, db, "/a"), Mtime: 1, Content: "hello"}}, nil); err != nil {
+		t.Fatalf("failed indexing a file: %v", err)
+	}
+
+	before := testDump(t, db)
+
+	if err := db.DeleteLabel(ctx, "x"); err != nil {
+		t.Fatalf("failed deleting a label: %v", err)
+	}
+	if err := db.DeleteFiles(ctx, []string{"/a"}); err != nil {
+		t.Fatalf("failed deleting a file: %v", err)
+	}
+	if err := db.UpdateLabel(ctx, "priority", "prio", "#ff0000"); err != nil {
+		t.Fatalf("failed updating a label: %v", err)
+	}
+	if err := db.AddFilesAndLinks(ctx, []string{"/b", "/c"}, []string{"prio=3", "z"}); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+
+	undone, err := db.UndoChanges(ctx, 4, os.Rename)
+	if err != nil {
+		t.Fatalf("failed undoing: %v", err)
+	}
+
+	ops := []string{}
+	for _, e := range undone {
+		ops = append(ops, e.Op)
+	}
+	expected := []string{OpAddFiles, OpUpdateLabel, OpDeleteFiles, OpDeleteLabel}
+	if !reflect.DeepEqual(ops, expected) {
+		t.Errorf("undone: got %v, expected %v", ops, expected)
+	}
+
+	if after := testDump(t, db); !reflect.DeepEqual(after, before) {
+		t.Errorf("rows after undoing differ:\ngot      %v\nexpected %v", after, before)
+	}
+
+	entries, err := db.GetHistory(HistoryFilter{Limit: 1})
+	if err != nil {
+		t.Fatalf("failed getting history: %v", err)
+	}
+	if entries[0].UndoneAt == 0 {
+		t.Error("expected the undone change to be marked")
+	}
+
+	// Undone changes are skipped, leaving the first changes
+	undone, err = db.UndoChanges(ctx, 10, os.Rename)
+	if err != nil {
+		t.Fatalf("failed undoing: %v", err)
+	}
+	if len(undone) != 5 {
+		t.Errorf("expected 5 more changes undone, got %d", len(undone))
+	}
+	if files := testFilePaths(t, db, FileFilter{}); len(files) != 0 {
+		t.Errorf("expected no files after undoing everything, got %v", files)
+	}
+
+	if _, err := db.UndoChanges(ctx, 1, os.Rename); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("expected ErrNothingToUndo, got %v", err)
+	}
+}
+
+func TestUndoUnknownTimes(t *testing.T) {
+	db := testNewDB(t)
+	ctx := context.TODO()
+
+	if err := db.AddFilesAndLinks(ctx, []string{"/a"}, []string{"x"}); err != nil {
+		t.Fatalf("failed adding files: %v", err)
+	}
+	// Files and links stored before the times were recorded
+	if _, err := db.db.Exec(`UPDATE File SET added_at = 0`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.db.Exec(`UPDATE FileInfo SET linked_at = 0`); err != nil {
+		t.Fatal(err)
+	}
+
+	before := testDump(t, db)
+
+	if err := db.DeleteFiles(ctx, []string{"/a"}); err != nil {
+		t.Fatalf("failed deleting a file: %v", err)
+	}
+	if _, err := db.UndoChanges(ctx, 1, os.Rename); err != nil {
+		t.Fatalf("failed undoing: %v", err)
+	}
+
+	if after := testDump(t, db); !reflect.DeepEqual(after, before) {
+		t.Errorf("rows after undoing differ:\ngot      %v\nexpected %v", after, before)
+	}
+}
+
+func TestUndoOperations(t *testing.T) {
+	ctx := context.TODO()
+
+	setup := func(t *testing.T) *DB {
+		t.Helper()
+
+		db := testNewDB(t)
+		links := map[string][]string{
+			"/a":     {"x", "y=1"},
+			"/b":     {"z", "y=2"},
+			"/dir/c": {"x"},
+		}
+		for path, labels := range links {
+			if err := db.AddFilesAndLinks(ctx, []string{path}, labels); err != nil {
+				t.Fatalf("failed adding %s: %v", path, err)
+			}
+		}
+		if _, err := db.AddLabelAliases(ctx, "x", []string{"ex"}, false); err != nil {
+			t.Fatalf("failed adding an alias: %v", err)
+		}
+		if _, err := db.AddLabelImplications(ctx, "z", []string{"w"}); err != nil {
+			t.Fatalf("failed adding an implication: %v", err)
+		}
+		if err := db.SetNote(ctx, "/a", "remember"); err != nil {
+			t.Fatalf("failed setting a note: %v", err)
+		}
+		if err := db.SaveSearch(ctx, "docs", FileFilter{PathPrefix: "/docs/"}); err != nil {
+			t.Fatalf("failed saving a search: %v", err)
+		}
+		if err := db.UpdateHashes(ctx, []HashEntry{
+			{FileId: testFileId(t, db, "/a"), Hash: "same", Mtime: 1},
+			{FileId: testFileId(t, db, "/b"), Hash: "same", Mtime: 1},
+		}); err != nil {
+			t.Fatalf("failed updating hashes: %v", err)
+		}
+
+		return db
+	}
+
+	noRename := func(string, string) error { return nil }
+
+	tests := []struct {
+		name   string
+		change func(db *DB) error
+		op     string
+		// Paths expected to be moved back on disk
+		renamed [][2]string
+	}{
+		{
+			name: "alias merging a label",
+			change: func(db *DB) error {
+				_, err := db.AddLabelAliases(ctx, "x", []string{"z"}, true)
+				return err
+			},
+			op: OpAddAliases,
+		},
+		{
+			name: "alias of a new label",
+			change: func(db *DB) error {
+				_, err := db.AddLabelAliases(ctx, "new", []string{"ex"}, false)
+				return err
+			},
+			op: OpAddAliases,
+		},
+		{
+			name:   "unalias",
+			change: func(db *DB) error { return db.DeleteLabelAliases(ctx, []string{"ex"}) },
+			op:     OpDeleteAliases,
+		},
+		{
+			name: "imply",
+			change: func(db *DB) error {
+				_, err := db.AddLabelImplications(ctx, "ex", []string{"y", "v"})
+				return err
+			},
+			op: OpAddImplications,
+		},
+		{
+			name:   "unimply",
+			change: func(db *DB) error { return db.DeleteLabelImplications(ctx, "z", []string{"w"}) },
+			op:     OpDeleteImplications,
+		},
+		{
+			name: "merge duplicates",
+			change: func(db *DB) error {
+				return db.MergeDuplicateLabels(ctx, testFileId(t, db, "/a"), []int64{testFileId(t, db, "/b")})
+			},
+			op: OpMergeDuplicates,
+		},
+		{
+			name: "move",
+			change: func(db *DB) error {
+				_, err := db.MoveFiles(ctx, "/dir", "/moved", noRename)
+				return err
+			},
+			op:      OpMoveFiles,
+			renamed: [][2]string{{"/moved", "/dir"}},
+		},
+		{
+			name: "relink",
+			change: func(db *DB) error {
+				moved := MovedFile{Paths: []string{"/elsewhere"}}
+				moved.Id, moved.Path = testFileId(t, db, "/a"), "/a"
+				return db.RelinkFiles(ctx, []MovedFile{moved})
+			},
+			op: OpRelinkFiles,
+		},
+		{
+			name:   "note",
+			change: func(db *DB) error { return db.SetNote(ctx, "/b", "later") },
+			op:     OpSetNote,
+		},
+		{
+			name:   "note of a new file",
+			change: func(db *DB) error { return db.SetNote(ctx, "/new", "later") },
+			op:     OpSetNote,
+		},
+		{
+			name:   "clear note",
+			change: func(db *DB) error { return db.SetNote(ctx, "/a", "") },
+			op:     OpSetNote,
+		},
+		{
+			name:   "save search",
+			change: func(db *DB) error { return db.SaveSearch(ctx, "docs", FileFilter{Labels: []string{"x"}}) },
+			op:     OpSaveSearch,
+		},
+		{
+			name:   "remove search",
+			change: func(db *DB) error { return db.DeleteSavedSearch(ctx, "docs") },
+			op:     OpDeleteSearch,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := setup(t)
+			before := testDump(t, db)
+
+			if err := test.change(db); err != nil {
+				t.Fatalf("failed making the change: %v", err)
+			}
+			if changed := testDump(t, db); reflect.DeepEqual(changed, before) {
+				t.Fatal("expected the change to change the rows")
+			}
+
+			var renamed [][2]string
+			undone, err := db.UndoChanges(ctx, 1, func(from string, to string) error {
+				renamed = append(renamed, [2]string{from, to})
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed undoing: %v", err)
+			}
+			if len(undone) != 1 || undone[0].Op != test.op {
+				t.Errorf("undone: got %+v, expected %s", undone, test.op)
+			}
+			if !reflect.DeepEqual(renamed, test.renamed) {
+				t.Errorf("renamed: got %v, expected %v", renamed, test.renamed)
+			}
+
+			if after := testDump(t, db); !reflect.DeepEqual(after, before) {
+				t.Errorf("rows after undoing differ:\ngot      %v\nexpected %v", after, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A label, optionally compared against the value of its links, e.g. `priority>=2`
+type labelTerm struct {
+	name  string
+	op    string
+	value string
+}
+
+// Longer operators go first, so that `>=` isn't read as `>`
+var valueOperators = []string{">=", "<=", "!=", "=", "<", ">"}
+
+var ErrInvalidValue = errors.New("invalid label value")
+
+func parseLabelTerm(s string) labelTerm {
+	for i := 1; i < len(s); i++ {
+		for _, op := range valueOperators {
+			if strings.HasPrefix(s[i:], op) {
+				return labelTerm{name: s[:i], op: op, value: s[i+len(op):]}
+			}
+		}
+	}
+
+	return labelTerm{name: s}
+}
+
+// LabelName strips the value comparison from a label, `priority>=2` becomes `priority`
+func LabelName(s string) string {
+	return parseLabelTerm(s).name
+}
+
+// splitLabelValue splits an assignment such as `priority=2` into the label name and its value.
+// The value is nil if none was assigned.
+func splitLabelValue(s string) (string, any, error) {
+	term := parseLabelTerm(s)
+
+	switch term.op {
+	case "":
+		return term.name, nil, nil
+	case "=":
+		return term.name, parseValue(term.value), nil
+	}
+
+	return "", nil, fmt.Errorf("%w: %s. values can only be assigned with '='", ErrInvalidValue, s)
+}
+
+const dateLayout = "2006-01-02"
+
+var relativeDates = map[string]int{
+	"yesterday": -1,
+	"today":     0,
+	"tomorrow":  1,
+}
+
+// parseValue gives the value a type: integers and real numbers are compared numerically,
+// everything else as text. Relative dates become ISO 8601 dates, which sort as text.
+func parseValue(s string) any {
+	if days, ok := relativeDates[strings.ToLower(s)]; ok {
+		return time.Now().AddDate(0, 0, days).Format(dateLayout)
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+
+	return s
+}
+
+// valueCondition compares the value of the FileInfo row against the term
+func valueCondition(term labelTerm) (string, any) {
+	value := parseValue(term.value)
+
+	switch value.(type) {
+	case int64, float64:
+		return "typeof(FileInfo.value) IN ('integer', 'real') AND FileInfo.value " + term.op + " ?", value
+	default:
+		return "typeof(FileInfo.value) = 'text' AND FileInfo.value != '' AND FileInfo.value " + term.op + " ?", value
+	}
+}
+
+var ErrInvalidTime = errors.New("invalid time")
+
+// Units of the durations accepted by parseTime
+var timeUnits = map[byte]time.Duration{
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// parseTime reads a point in time as either a duration back from now, e.g. 12h, 7d or 2w,
+// a relative date, e.g. yesterday, or a date in the YYYY-MM-DD format. Dates start at midnight.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if days, ok := relativeDates[strings.ToLower(s)]; ok {
+		y, m, d := now.Date()
+		return time.Date(y, m, d+days, 0, 0, 0, 0, now.Location()), nil
+	}
+
+	if len(s) > 1 {
+		if unit, ok := timeUnits[s[len(s)-1]]; ok {
+			n, err := strconv.Atoi(s[:len(s)-1])
+			if err == nil && n >= 0 {
+				return now.Add(-time.Duration(n) * unit), nil
+			}
+		}
+	}
+
+	t, err := time.ParseInLocation(dateLayout, s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s. use a duration like 7d, a date like %s or today", ErrInvalidTime, s, dateLayout)
+	}
+
+	return t, nil
+}
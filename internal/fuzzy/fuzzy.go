@@ -0,0 +1,95 @@
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+)
+
+// Distance returns the optimal string alignment distance between a and b:
+// the amount of insertions, deletions, substitutions and transpositions
+// of adjacent characters needed to turn one into the other.
+func Distance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// Three rows are enough to detect transpositions
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(rb)]
+}
+
+func min(v int, vs ...int) int {
+	for _, n := range vs {
+		if n < v {
+			v = n
+		}
+	}
+
+	return v
+}
+
+// maxDistance is the largest distance a candidate may have to still be suggested for q
+func maxDistance(q string) int {
+	return len([]rune(q))/3 + 1
+}
+
+type match struct {
+	index    int
+	distance int
+}
+
+// Rank returns the indices of up to n candidates closest to q, best first.
+// Matching is case-insensitive. Candidates containing q are always considered.
+func Rank(q string, candidates []string, n int) []int {
+	q = strings.ToLower(q)
+	limit := maxDistance(q)
+
+	var matches []match
+	for i, c := range candidates {
+		c = strings.ToLower(c)
+
+		d := Distance(q, c)
+		if d > limit && !strings.Contains(c, q) {
+			continue
+		}
+
+		matches = append(matches, match{index: i, distance: d})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+
+	res := make([]int, 0, len(matches))
+	for _, m := range matches {
+		res = append(res, m.index)
+	}
+
+	return res
+}
@@ -0,0 +1,52 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"label", "label", 0},
+		{"label", "lable", 1},
+		{"reprot.txt", "report.txt", 1},
+		{"kitten", "sitting", 3},
+		{"todo", "to-do", 1},
+		{"ёжик", "ежик", 1},
+	}
+
+	for _, test := range tests {
+		if d := Distance(test.a, test.b); d != test.distance {
+			t.Errorf("distance between %q and %q: got %d, expected %d", test.a, test.b, d, test.distance)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	candidates := []string{"work", "homework", "urgent", "Wrok", "review", "w"}
+
+	tests := []struct {
+		q       string
+		n       int
+		indices []int
+	}{
+		{q: "wrok", n: 3, indices: []int{3, 0}},
+		{q: "work", n: 3, indices: []int{0, 3, 1}},
+		{q: "work", n: 1, indices: []int{0}},
+		{q: "urgnet", n: 3, indices: []int{2}},
+		{q: "zzzzzz", n: 3, indices: []int{}},
+	}
+
+	for _, test := range tests {
+		indices := Rank(test.q, candidates, test.n)
+		if !reflect.DeepEqual(indices, test.indices) {
+			t.Errorf("rank of %q: got %v, expected %v", test.q, indices, test.indices)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package os
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// FileId returns the device and inode numbers of the file
+func FileId(info fs.FileInfo) (uint64, uint64) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+
+	return uint64(stat.Dev), uint64(stat.Ino)
+}
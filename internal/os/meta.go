@@ -0,0 +1,108 @@
+package os
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Metadata of a file. Symlinks are described themselves, not their targets
+type FileMeta struct {
+	Size    int64
+	ModTime time.Time
+	Mode    fs.FileMode
+	Mime    string
+	// Identify the file across renames, zero where unsupported
+	Device uint64
+	Inode  uint64
+}
+
+func Stat(path string) (*FileMeta, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &FileMeta{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Mode:    info.Mode(),
+		Mime:    DetectMime(path, info.Mode()),
+	}
+	meta.Device, meta.Inode = FileId(info)
+
+	return meta, nil
+}
+
+// Amount of bytes needed to detect the type of a file, see http.DetectContentType
+const sniffSize = 512
+
+// DetectMime guesses the MIME type of a file from its extension, falling back to its contents.
+// Anything other than regular files gets one of the inode/* types.
+func DetectMime(path string, mode fs.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "inode/directory"
+	case mode&fs.ModeSymlink != 0:
+		return "inode/symlink"
+	case mode&fs.ModeNamedPipe != 0:
+		return "inode/fifo"
+	case mode&fs.ModeSocket != 0:
+		return "inode/socket"
+	case mode&fs.ModeCharDevice != 0:
+		return "inode/chardevice"
+	case mode&fs.ModeDevice != 0:
+		return "inode/blockdevice"
+	}
+
+	if t := mime.TypeByExtension(filepath.Ext(path)); len(t) > 0 {
+		return mediaType(t)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	buf := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return ""
+	}
+
+	return mediaType(http.DetectContentType(buf[:n]))
+}
+
+// mediaType strips the parameters from a MIME type, e.g. "; charset=utf-8"
+func mediaType(t string) string {
+	mt, _, err := mime.ParseMediaType(t)
+	if err != nil {
+		return t
+	}
+
+	return mt
+}
+
+// Hash returns the hex encoded SHA-256 of the file contents
+func Hash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -2,7 +2,9 @@ package os
 
 import (
 	"os"
+	"os/user"
 	"path/filepath"
+	"time"
 )
 
 func ConfigPath() string {
@@ -17,6 +19,22 @@ func FileExists(path string) bool {
 	return err == nil
 }
 
+func IsDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// FileModTime returns the modification time of the file, reporting false for directories
+// and paths which can't be read
+func FileModTime(path string) (time.Time, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return time.Time{}, false
+	}
+
+	return info.ModTime(), true
+}
+
 func CreateFile(path string) error {
 	dir, _ := filepath.Split(path)
 
@@ -32,3 +50,16 @@ func CreateFile(path string) error {
 
 	return file.Close()
 }
+
+// Username returns the name of the current user, or an empty string if it's unknown
+func Username() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	if name := os.Getenv("USER"); len(name) > 0 {
+		return name
+	}
+
+	return os.Getenv("USERNAME")
+}